@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAPIKeyNotFound é retornado pelo [APIKeyStore] quando a chave não existe.
+	ErrAPIKeyNotFound = errors.New("api key não encontrada")
+	// ErrAPIKeyRejected indica uma API key desconhecida, expirada ou com segredo incorreto.
+	ErrAPIKeyRejected = errors.New("api key rejeitada")
+)
+
+// APIKey é o registro persistido de uma API key. O segredo nunca é
+// armazenado; apenas seu hash SHA-256.
+type APIKey struct {
+	// ID é a parte pública da chave (prefixo + identificador), usada na busca.
+	ID string
+	// Hash é o SHA-256 em hexadecimal da chave completa.
+	Hash string
+	// Data são os claims injetados no contexto, como os campos de um JWT.
+	Data map[ContextValue]any
+	// Scopes são injetados no claim configurado em [WithScopesClaim].
+	Scopes []string
+	// ExpiresAt é o instante de expiração; zero indica que a chave não expira.
+	ExpiresAt time.Time
+}
+
+// APIKeyStore persiste API keys, buscadas pelo ID público.
+type APIKeyStore interface {
+	// Save grava uma nova API key.
+	Save(ctx context.Context, key APIKey) error
+	// Find retorna a chave pelo ID ou [ErrAPIKeyNotFound].
+	Find(ctx context.Context, id string) (APIKey, error)
+}
+
+// WithAPIKeys habilita autenticação por API key, lida do header X-API-Key ou
+// de Authorization: ApiKey <chave>. Os campos de [APIKey.Data] e os escopos da
+// chave ficam disponíveis no contexto como os campos de um JWT.
+//
+//	a := auth.New("secret", auth.WithAPIKeys(store))
+//	r.Use(a.Middleware("client_id"))
+func WithAPIKeys(store APIKeyStore) Option {
+	return func(a *Authenticator) {
+		a.apiKeys = store
+	}
+}
+
+// GenerateAPIKey gera uma API key no formato <prefix>_<id>_<segredo> e o
+// registro a ser persistido. A chave completa deve ser entregue ao integrador
+// uma única vez; apenas o registro é armazenado.
+//
+//	key, record, err := auth.GenerateAPIKey("cgi_live")
+//	record.Data = map[auth.ContextValue]any{"client_id": "acme"}
+//	record.Scopes = []string{"pedidos:ler"}
+//	err = store.Save(ctx, record)
+func GenerateAPIKey(prefix string) (string, APIKey, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+
+	publicID := hex.EncodeToString(id)
+	if prefix != "" {
+		publicID = prefix + "_" + publicID
+	}
+	key := publicID + "_" + hex.EncodeToString(secret)
+
+	return key, APIKey{ID: publicID, Hash: hashToken(key)}, nil
+}
+
+// verifyAPIKey busca a chave pelo ID público e compara o hash em tempo constante
+func (a *Authenticator) verifyAPIKey(ctx context.Context, key string) (*internalClaims, error) {
+	if a.apiKeys == nil {
+		return nil, fmt.Errorf("%w: api keys desabilitadas", ErrAPIKeyRejected)
+	}
+
+	separator := strings.LastIndex(key, "_")
+	if separator <= 0 {
+		return nil, fmt.Errorf("%w: formato de api key inválido", ErrMalformedToken)
+	}
+
+	record, err := a.apiKeys.Find(ctx, key[:separator])
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrAPIKeyRejected
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(record.Hash)) != 1 {
+		return nil, ErrAPIKeyRejected
+	}
+	if !record.ExpiresAt.IsZero() && a.now().After(record.ExpiresAt) {
+		return nil, fmt.Errorf("%w: api key expirada", ErrAPIKeyRejected)
+	}
+
+	data := make(map[ContextValue]any, len(record.Data)+2)
+	maps.Copy(data, record.Data)
+	data["api_key_id"] = record.ID
+	if len(record.Scopes) > 0 {
+		data[a.scopesClaim] = record.Scopes
+	}
+	return &internalClaims{Data: data, local: true}, nil
+}
+
+// MemoryAPIKeyStore é um [APIKeyStore] em memória, adequado para testes.
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryAPIKeyStore cria um [MemoryAPIKeyStore] vazio.
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]APIKey)}
+}
+
+// Save grava a API key, substituindo uma existente com o mesmo ID (ex.: para
+// alterar escopos ou expiração).
+func (s *MemoryAPIKeyStore) Save(ctx context.Context, key APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+// Find retorna a API key pelo ID sob um lock de leitura, sem bloquear
+// verificações concorrentes.
+func (s *MemoryAPIKeyStore) Find(ctx context.Context, id string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Delete remove a API key, revogando-a imediatamente.
+func (s *MemoryAPIKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, id)
+	return nil
+}
@@ -29,6 +29,9 @@
 //	    }
 //	}
 //
+// Para receber os claims de volta como struct, sem conversões de float64,
+// use [NewTyped] e [GetClaims].
+//
 // # Opções de configuração
 //
 // Use as funções [WithCookieName], [WithBasicAuthValidator] e [WithCryptService]
@@ -41,9 +44,61 @@
 //	    }),
 //	)
 //
+// # Chaves assimétricas
+//
+// Além do segredo HMAC, os tokens podem ser assinados com RSA (RS256), ECDSA
+// (ES256/ES384/ES512) ou Ed25519 (EdDSA). Serviços que apenas verificam tokens
+// recebem somente a chave pública e não precisam conhecer nenhum segredo:
+//
+//	// serviço emissor
+//	priv, _ := crypt.LoadRSAPrivateKeyFromPEM(privatePEM)
+//	issuer := auth.New("", auth.WithRSAPrivateKey(priv))
+//
+//	// serviço verificador
+//	pub, _ := crypt.LoadRSAPublicKeyFromPEM(publicPEM)
+//	verifier := auth.New("", auth.WithRSAPublicKey(pub))
+//
+// As chaves públicas podem ser publicadas com [Authenticator.JWKSHandler] e
+// consumidas por outros serviços com [WithRemoteJWKS].
+//
+// # Autorização
+//
+// Após o [Authenticator.Middleware], restrinja rotas por papel, escopo ou regra
+// sobre os claims. Requisições autenticadas sem permissão recebem 403:
+//
+//	r.Use(a.Middleware("user_id"))
+//	r.With(a.RequireRoles("admin")).Delete("/usuarios/{id}", handler)
+//	r.With(a.RequireAny(a.HasRole("admin"), a.HasScope("relatorios:ler"))).Get("/relatorios", handler)
+//
+// # Claims registrados
+//
+// Para que tokens emitidos por um serviço não sejam aceitos por outro, configure
+// o emissor e o público esperado. Sign aceita [SignOption] para "sub", "aud" e "nbf":
+//
+//	issuer := auth.New("secret", auth.WithIssuer("auth"))
+//	token, _ := issuer.Sign(claims, time.Hour, auth.WithSubject("42"), auth.WithAudience("financeiro"))
+//
+//	verifier := auth.New("secret",
+//	    auth.WithIssuer("auth"),
+//	    auth.WithExpectedAudience("financeiro"),
+//	    auth.WithLeeway(30*time.Second),
+//	)
+//
+// # Rotação de segredos
+//
+// Com [WithHMACKeyring], vários segredos identificados por kid são aceitos ao
+// mesmo tempo e apenas o atual assina novos tokens. O kid é gravado no header
+// do token, permitindo trocar o segredo sem invalidar as sessões em andamento:
+//
+//	a := auth.New("", auth.WithHMACKeyring("2025-02", map[string]string{
+//	    "2025-01": segredoAnterior,
+//	    "2025-02": segredoAtual,
+//	}))
+//
 // # Segurança
 //
-//   - Tokens são assinados com HMAC-SHA256. Algoritmos diferentes são rejeitados.
+//   - Tokens são assinados com HMAC-SHA256 ou com a chave privada configurada.
+//     Apenas algoritmos com chave configurada são aceitos; os demais são rejeitados.
 //   - Basic Auth é desabilitado por padrão; só funciona com [WithBasicAuthValidator].
 //   - Tokens sem ExpiresAt são rejeitados.
 //   - Cookie só é lido se [WithCookieName] for configurado.
@@ -52,6 +107,7 @@ package auth
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -85,14 +141,22 @@ type CustomClaims interface {
 // armazenados nos claims antes de injetá-los no contexto.
 // Configurado via [WithCryptService].
 //
-// O [Authenticator] tenta primeiro [DecryptWithMasterKeySimple] (AES) e,
-// em caso de falha, tenta [DecryptData] (híbrido). Se ambos falharem,
-// o valor original é usado sem erro.
+// Se o serviço implementar [EnvelopeDecrypter] (como o crypt.CryptService),
+// o algoritmo e a chave são escolhidos pelo envelope do valor. Caso contrário,
+// o [Authenticator] tenta primeiro [DecryptWithMasterKeySimple] (AES) e,
+// em caso de falha, tenta [DecryptData] (híbrido). Se a descriptografia
+// falhar, o valor original é usado sem erro.
 type CryptService interface {
 	DecryptWithMasterKeySimple(encryptedData string) ([]byte, error)
 	DecryptData(encryptedData string) ([]byte, error)
 }
 
+// EnvelopeDecrypter é implementado por serviços que descriptografam valores
+// autodescritivos, escolhendo o algoritmo e a chave pelo próprio valor.
+type EnvelopeDecrypter interface {
+	Decrypt(encryptedData string) ([]byte, error)
+}
+
 // Option é uma função de configuração aplicada ao [Authenticator] em [New].
 type Option func(*Authenticator)
 
@@ -133,19 +197,72 @@ func WithCryptService(svc CryptService) Option {
 // Authenticator gerencia a autenticação JWT e Basic Auth.
 // Crie uma instância com [New].
 type Authenticator struct {
-	secretKey          []byte
-	cookieName         string
-	basicAuthValidator func(clientID, secret string) bool
-	cryptService       CryptService
+	signingKey          signingKey
+	verificationKeys    []verificationKey
+	remoteKeys          *remoteKeySet
+	refresh             *refreshConfig
+	revocation          *revocationConfig
+	lockout             *lockoutConfig
+	sliding             *slidingConfig
+	clientCredentials   *clientCredentialsConfig
+	rolesClaim          ContextValue
+	scopesClaim         ContextValue
+	issuer              string
+	allowedIssuers      []string
+	audience            string
+	leeway              time.Duration
+	clock               func() time.Time
+	unauthorizedHandler UnauthorizedHandler
+	failureHook         func(r *http.Request, err error)
+	apiKeys             APIKeyStore
+	v1                  *V1Compat
+	cookieName          string
+	cookiePolicy        CookiePolicy
+	basicAuthValidator  func(clientID, secret string) bool
+	cryptService        CryptService
 }
 
-// internalClaims encapsula os dados do sistema e adiciona jwt.RegisteredClaims
+// internalClaims encapsula os dados do sistema e adiciona jwt.RegisteredClaims.
+// rawData preserva o JSON original de "data" para a decodificação tipada.
+// local marca credenciais validadas pelo próprio Authenticator (Basic e API
+// key), que não carregam claims de emissão.
 type internalClaims struct {
-	Data map[ContextValue]any `json:"data"`
+	Data     map[ContextValue]any `json:"data"`
+	AuthTime *jwt.NumericDate     `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+	rawData json.RawMessage
+	flavor  TokenFlavor
+	local   bool
+}
+
+// UnmarshalJSON decodifica os claims preservando o JSON original de "data"
+func (c *internalClaims) UnmarshalJSON(b []byte) error {
+	type plain internalClaims
+	if err := json.Unmarshal(b, (*plain)(c)); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	c.rawData = raw.Data
+	return nil
+}
+
+// signedClaims é o formato de emissão: "data" aceita um mapa ou uma struct.
+// AuthTime marca o início da sessão renovada por [WithSlidingSession].
+type signedClaims struct {
+	Data     any              `json:"data"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // New cria um [Authenticator] com a chave secreta e as opções fornecidas.
+// Se secretKey for vazia, tokens HMAC não são aceitos e a assinatura depende
+// de uma chave privada configurada via opções (ex.: [WithRSAPrivateKey]).
 //
 //	a := auth.New("minha-chave-secreta",
 //	    auth.WithCookieName("SESSION"),
@@ -153,7 +270,12 @@ type internalClaims struct {
 //	)
 func New(secretKey string, opts ...Option) *Authenticator {
 	a := &Authenticator{
-		secretKey: []byte(secretKey),
+		rolesClaim:  "role",
+		scopesClaim: "scope",
+	}
+	if secretKey != "" {
+		a.addHMACKey("", []byte(secretKey))
+		a.signingKey = signingKey{method: jwt.SigningMethodHS256, key: []byte(secretKey)}
 	}
 	for _, opt := range opts {
 		opt(a)
@@ -162,19 +284,60 @@ func New(secretKey string, opts ...Option) *Authenticator {
 }
 
 // Sign gera e assina um token JWT com os claims fornecidos e o tempo de expiração.
-// O token é assinado com HMAC-SHA256 usando a chave configurada em [New].
+// Cada token recebe um identificador único (jti), usado na revogação, e o emissor
+// configurado em [WithIssuer]. Os demais claims registrados são definidos com
+// [SignOption] ([WithSubject], [WithAudience], [WithNotBefore]).
+// O token é assinado com HMAC-SHA256 usando a chave configurada em [New], ou com
+// a chave privada configurada via [WithRSAPrivateKey], [WithECDSAPrivateKey] ou
+// [WithEd25519PrivateKey], que tem precedência.
 //
 //	token, err := a.Sign(UserClaims{UserID: 1, Role: "admin"}, 24*time.Hour)
-func (a *Authenticator) Sign(claims CustomClaims, expireIn time.Duration) (string, error) {
-	internal := internalClaims{
-		Data: claims.GetFields(),
+//	token, err := a.Sign(claims, time.Hour, auth.WithSubject("1"), auth.WithAudience("api-financeiro"))
+func (a *Authenticator) Sign(claims CustomClaims, expireIn time.Duration, opts ...SignOption) (string, error) {
+	return a.sign(claims.GetFields(), expireIn, opts...)
+}
+
+// sign assina um token cujo claim "data" é a serialização JSON de data
+func (a *Authenticator) sign(data any, expireIn time.Duration, opts ...SignOption) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := a.now()
+	internal := signedClaims{
+		Data: data,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireIn)),
+			ID:        jti,
+			Issuer:    a.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expireIn)),
 		},
 	}
+	for _, opt := range opts {
+		opt(&internal.RegisteredClaims)
+	}
+	if a.sliding != nil {
+		internal.AuthTime = internal.IssuedAt
+	}
+
+	return a.signClaims(internal)
+}
+
+// signClaims assina os claims com a chave de assinatura configurada
+func (a *Authenticator) signClaims(internal signedClaims) (string, error) {
+	if a.signingKey.key == nil {
+		return "", ErrNoSigningKey
+	}
+	if a.signingKey.method == nil {
+		return "", ErrUnsupportedKey
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, internal)
-	tokenString, err := token.SignedString(a.secretKey)
+	token := jwt.NewWithClaims(a.signingKey.method, internal)
+	if a.signingKey.id != "" {
+		token.Header["kid"] = a.signingKey.id
+	}
+	tokenString, err := token.SignedString(a.signingKey.key)
 	if err != nil {
 		return "", err
 	}
@@ -186,7 +349,9 @@ func (a *Authenticator) Sign(claims CustomClaims, expireIn time.Duration) (strin
 // os values especificados no contexto para uso nos handlers.
 //
 // O token é lido do header Authorization (Bearer ou Basic) ou do cookie
-// configurado em [WithCookieName]. Requisições sem token válido recebem 401.
+// configurado em [WithCookieName]. Requisições sem token válido recebem 401 com
+// o header WWW-Authenticate; o motivo da falha pode ser renderizado com
+// [WithUnauthorizedHandler] e observado com [WithFailureHook].
 //
 // Os values injetados no contexto podem ser recuperados com [GetFromContext]:
 //
@@ -194,54 +359,99 @@ func (a *Authenticator) Sign(claims CustomClaims, expireIn time.Duration) (strin
 //
 //	// no handler:
 //	userID, _ := auth.GetFromContext[int64](r.Context(), "user_id")
+//
+// Todos os claims ficam disponíveis em [ClaimsFromContext] e são usados pelos
+// middlewares de autorização, como [Authenticator.RequireRoles].
 func (a *Authenticator) Middleware(values ...ContextValue) func(next http.Handler) http.Handler {
+	return a.middleware(nil, values)
+}
+
+// middleware implementa o [Authenticator.Middleware], aplicando check (se
+// informado) aos claims verificados antes de liberar a requisição
+func (a *Authenticator) middleware(check func(claims *internalClaims) error, values []ContextValue) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-			headerToken := request.Header.Get("Authorization")
-
-			if a.cookieName != "" {
-				for _, cookie := range request.Cookies() {
-					if cookie.Name == a.cookieName {
-						headerToken = cookie.Value
-						break
-					}
-				}
+			claims, err := a.verifyToken(a.requestContext(request), a.credential(request))
+			if err == nil && check != nil {
+				err = check(claims)
 			}
-
-			claims, isValid := a.verifyToken(headerToken)
-			if !isValid {
-				response.WriteHeader(http.StatusUnauthorized)
+			if err != nil {
+				a.unauthorized(response, request, err)
 				return
 			}
 
-			ctx := request.Context()
-			for _, value := range values {
-				field, exists := claims.Data[value]
-				if !exists {
-					continue
-				}
-
-				if a.cryptService != nil {
-					if fieldStr, ok := field.(string); ok && fieldStr != "" {
-						if decrypted, err := a.cryptService.DecryptWithMasterKeySimple(fieldStr); err == nil {
-							ctx = context.WithValue(ctx, value, decrypted)
-							continue
-						}
-						if decrypted, err := a.cryptService.DecryptData(fieldStr); err == nil {
-							ctx = context.WithValue(ctx, value, decrypted)
-							continue
-						}
-					}
-				}
-
-				ctx = context.WithValue(ctx, value, field)
-			}
+			a.slide(response, request, claims)
+			a.reportFlavor(request, claims)
 
+			ctx := a.contextWithClaims(request.Context(), claims, values)
 			next.ServeHTTP(response, request.WithContext(ctx))
 		})
 	}
 }
 
+// Authenticate verifica a credencial fora do HTTP (gRPC, filas, jobs) com as
+// mesmas regras do [Authenticator.Middleware] e retorna o contexto com os
+// claims e os values informados, recuperáveis com [GetFromContext] e
+// [ClaimsFromContext].
+//
+// credential é o valor que iria no header Authorization ("Bearer <token>",
+// "Basic <base64>", "ApiKey <chave>") ou apenas o token JWT. Em caso de falha,
+// o erro pode ser comparado com os motivos de [ErrMissingToken] a
+// [ErrCredentialsLocked]; [ReasonMessage] retorna a mensagem pública.
+//
+//	ctx, err := a.Authenticate(ctx, msg.Headers["authorization"], "user_id")
+func (a *Authenticator) Authenticate(ctx context.Context, credential string, values ...ContextValue) (context.Context, error) {
+	claims, err := a.verifyToken(ctx, credential)
+	if err != nil {
+		return ctx, err
+	}
+	return a.contextWithClaims(ctx, claims, values), nil
+}
+
+// contextWithClaims injeta os claims, o tipo de credencial e os values
+// informados no contexto, descriptografando-os com o [CryptService] se configurado
+func (a *Authenticator) contextWithClaims(ctx context.Context, claims *internalClaims, values []ContextValue) context.Context {
+	ctx = context.WithValue(ctx, claimsContextKey{}, claims.Data)
+	if a.v1 != nil {
+		ctx = context.WithValue(ctx, flavorContextKey{}, claims.tokenFlavor())
+	}
+
+	for _, value := range values {
+		field, exists := claims.Data[value]
+		if !exists {
+			continue
+		}
+
+		if fieldStr, ok := field.(string); ok && fieldStr != "" {
+			if decrypted, ok := a.decrypt(fieldStr); ok {
+				ctx = context.WithValue(ctx, value, decrypted)
+				continue
+			}
+		}
+
+		ctx = context.WithValue(ctx, value, field)
+	}
+	return ctx
+}
+
+// decrypt descriptografa o valor com o [CryptService], se configurado
+func (a *Authenticator) decrypt(value string) ([]byte, bool) {
+	if a.cryptService == nil {
+		return nil, false
+	}
+	if decrypter, ok := a.cryptService.(EnvelopeDecrypter); ok {
+		decrypted, err := decrypter.Decrypt(value)
+		return decrypted, err == nil
+	}
+	if decrypted, err := a.cryptService.DecryptWithMasterKeySimple(value); err == nil {
+		return decrypted, true
+	}
+	if decrypted, err := a.cryptService.DecryptData(value); err == nil {
+		return decrypted, true
+	}
+	return nil, false
+}
+
 // GetFromContext recupera um valor tipado do contexto injetado pelo [Authenticator.Middleware].
 // Retorna o valor e true se encontrado e do tipo correto, ou o zero value e false caso contrário.
 //
@@ -253,62 +463,137 @@ func GetFromContext[T any](ctx context.Context, key ContextValue) (T, bool) {
 	return v, ok
 }
 
-// verifyToken verifica e retorna as claims do token
-func (a *Authenticator) verifyToken(bearerToken string) (*internalClaims, bool) {
+// credential retorna o token da requisição: o cookie configurado, se presente,
+// o header Authorization ou, com [WithAPIKeys], o header X-API-Key
+func (a *Authenticator) credential(request *http.Request) string {
+	if value, ok := a.sessionCookie(request); ok {
+		return value
+	}
+	if authorization := request.Header.Get("Authorization"); authorization != "" {
+		return authorization
+	}
+	if a.apiKeys != nil {
+		if key := request.Header.Get("X-API-Key"); key != "" {
+			return "ApiKey " + key
+		}
+	}
+	return ""
+}
+
+// verifyToken verifica e retorna as claims do token, ou o motivo da falha
+func (a *Authenticator) verifyToken(ctx context.Context, bearerToken string) (*internalClaims, error) {
 	tokenType, headerToken := extractToken(bearerToken)
 
 	if headerToken == "" {
-		return nil, false
+		return nil, ErrMissingToken
 	}
 
 	if tokenType == "Basic" {
-		return a.verifyBasicToken(headerToken)
+		return a.verifyBasicToken(ctx, headerToken)
+	}
+
+	if tokenType == "ApiKey" {
+		return a.verifyAPIKey(ctx, headerToken)
 	}
 
-	return a.verifyJWTToken(headerToken)
+	return a.verifyJWTToken(ctx, headerToken)
 }
 
-func (a *Authenticator) verifyBasicToken(encoded string) (*internalClaims, bool) {
-	if a.basicAuthValidator == nil {
-		return nil, false
+func (a *Authenticator) verifyBasicToken(ctx context.Context, encoded string) (*internalClaims, error) {
+	unvalidated := a.basicAuthValidator == nil && a.v1 != nil && a.v1.AllowUnvalidatedBasic
+	if a.basicAuthValidator == nil && !unvalidated {
+		return nil, fmt.Errorf("%w: basic auth desabilitado", ErrBasicAuthRejected)
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return nil, false
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
 	}
 
 	parts := strings.Split(string(decoded), ":")
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-		return nil, false
+		return nil, fmt.Errorf("%w: credenciais basic incompletas", ErrMalformedToken)
 	}
 
-	if !a.basicAuthValidator(parts[0], parts[1]) {
-		return nil, false
+	if unvalidated {
+		return a.verifyV1Basic(parts[0], parts[1]), nil
 	}
 
-	claims := &internalClaims{Data: map[ContextValue]any{
-		"client_id": parts[0],
-		"secret":    parts[1],
-	}}
-	return claims, true
+	valid, err := a.guardCredentials(ctx, parts[0], func() bool {
+		return a.basicAuthValidator(parts[0], parts[1])
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrBasicAuthRejected
+	}
+
+	claims := &internalClaims{
+		Data: map[ContextValue]any{
+			"client_id": parts[0],
+			"secret":    parts[1],
+		},
+		local: true,
+	}
+	return claims, nil
 }
 
-func (a *Authenticator) verifyJWTToken(tokenString string) (*internalClaims, bool) {
+func (a *Authenticator) verifyJWTToken(ctx context.Context, tokenString string) (*internalClaims, error) {
+	claims, err := a.parseJWTCompat(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.revocation != nil {
+		revoked, err := a.isRevoked(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao consultar revogação: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// parseJWT valida a assinatura e os claims registrados do token e retorna suas claims.
+// Os erros são classificados em [ErrMalformedToken], [ErrTokenExpired],
+// [ErrInvalidSignature] ou [ErrInvalidClaims].
+func (a *Authenticator) parseJWT(tokenString string) (*internalClaims, error) {
 	claims := &internalClaims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		key, ok := a.keyFor(token)
+		if !ok {
 			return nil, fmt.Errorf("algoritmo de assinatura inesperado: %v", token.Header["alg"])
 		}
-		return a.secretKey, nil
-	})
-
-	if err != nil || !token.Valid || claims.ExpiresAt == nil {
-		return nil, false
+		return key, nil
+	}, a.parserOptions()...)
+	if err != nil {
+		return nil, classifyJWTError(err)
 	}
+	if !token.Valid {
+		return nil, ErrInvalidSignature
+	}
+	if err := a.checkIssuers(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// errorResponse é o corpo JSON das respostas de erro, no mesmo formato do pacote formatter
+type errorResponse struct {
+	Message string `json:"message"`
+}
 
-	return claims, true
+// writeError escreve uma resposta de erro JSON com o status informado
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Message: message})
 }
 
 // extractToken separa o tipo do token do valor
@@ -0,0 +1,1619 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testClaims struct {
+	UserID int64
+	Role   string
+}
+
+func (c testClaims) GetFields() map[ContextValue]any {
+	return map[ContextValue]any{
+		"user_id": c.UserID,
+		"role":    c.Role,
+	}
+}
+
+// serve executa o middleware com o header Authorization informado e retorna o status e o role do contexto
+func serve(a *Authenticator, authorization string) (int, string) {
+	var role string
+	handler := a.Middleware("role")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ = GetFromContext[string](r.Context(), "role")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, role
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		issuer   *Authenticator
+		verifier *Authenticator
+	}{
+		{"HMAC", New("secret"), New("secret")},
+		{"RSA", New("", WithRSAPrivateKey(rsaKey)), New("", WithRSAPublicKey(&rsaKey.PublicKey))},
+		{"ECDSA", New("", WithECDSAPrivateKey(ecKey)), New("", WithECDSAPublicKey(&ecKey.PublicKey))},
+		{"Ed25519", New("", WithEd25519PrivateKey(edKey)), New("", WithEd25519PublicKey(edPub))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.issuer.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			code, role := serve(tt.verifier, "Bearer "+token)
+			if code != http.StatusOK || role != "admin" {
+				t.Errorf("Middleware() = %d, %q, want 200, \"admin\"", code, role)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsUnconfiguredAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacToken, err := New("secret").Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaToken, err := New("", WithRSAPrivateKey(rsaKey)).Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier *Authenticator
+		token    string
+	}{
+		{"HMAC token on public key verifier", New("", WithRSAPublicKey(&rsaKey.PublicKey)), hmacToken},
+		{"RSA token on HMAC verifier", New("secret"), rsaToken},
+		{"HMAC token with wrong secret", New("other"), hmacToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := serve(tt.verifier, "Bearer "+tt.token); code != http.StatusUnauthorized {
+				t.Errorf("Middleware() = %d, want 401", code)
+			}
+		})
+	}
+}
+
+func TestSignWithoutKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New("", WithRSAPublicKey(&rsaKey.PublicKey))
+	if _, err := a.Sign(testClaims{}, time.Hour); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Sign() error = %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := New("", WithECDSAPrivateKey(oldKey))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.JWKSHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	verifier := New("", WithRemoteJWKS(server.URL, time.Hour))
+
+	oldToken, err := issuer.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, role := serve(verifier, "Bearer "+oldToken); code != http.StatusOK || role != "admin" {
+		t.Fatalf("Middleware() = %d, %q, want 200, \"admin\"", code, role)
+	}
+
+	// rotação no emissor: o kid novo força uma nova busca do JWKS
+	issuer = New("", WithECDSAPublicKey(&oldKey.PublicKey), WithRSAPrivateKey(newKey))
+	verifier.remoteKeys.fetchedAt = time.Now().Add(-time.Minute)
+
+	newToken, err := issuer.Sign(testClaims{Role: "user"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, role := serve(verifier, "Bearer "+newToken); code != http.StatusOK || role != "user" {
+		t.Errorf("Middleware() = %d, %q, want 200, \"user\"", code, role)
+	}
+	if code, _ := serve(verifier, "Bearer "+oldToken); code != http.StatusOK {
+		t.Errorf("Middleware() with old key = %d, want 200", code)
+	}
+
+	forged, err := New("secret").Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := serve(verifier, "Bearer "+forged); code != http.StatusUnauthorized {
+		t.Errorf("Middleware() with HMAC token = %d, want 401", code)
+	}
+}
+
+func TestRemoteJWKSFetch(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		requests int
+		issuer   = New("", WithECDSAPrivateKey(oldKey))
+		gate     chan struct{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		current, wait := issuer, gate
+		mu.Unlock()
+		if wait != nil {
+			<-wait
+		}
+		current.JWKSHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+	// expire faz o cache parecer buscado há d
+	verifier := New("", WithRemoteJWKS(server.URL, time.Hour))
+	expire := func(d time.Duration) {
+		verifier.remoteKeys.mu.Lock()
+		verifier.remoteKeys.fetchedAt = time.Now().Add(-d)
+		verifier.remoteKeys.mu.Unlock()
+	}
+
+	oldToken, _ := issuer.Sign(testClaims{Role: "admin"}, time.Hour)
+	if code, _ := serve(verifier, "Bearer "+oldToken); code != http.StatusOK {
+		t.Fatalf("Middleware() = %d, want 200", code)
+	}
+
+	t.Run("cache servido durante a atualização", func(t *testing.T) {
+		mu.Lock()
+		gate = make(chan struct{})
+		mu.Unlock()
+		expire(2 * time.Hour)
+
+		done := make(chan int)
+		go func() {
+			code, _ := serve(verifier, "Bearer "+oldToken)
+			done <- code
+		}()
+		select {
+		case code := <-done:
+			if code != http.StatusOK {
+				t.Errorf("Middleware() = %d, want 200", code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Middleware() bloqueado pela busca do JWKS")
+		}
+
+		mu.Lock()
+		close(gate)
+		gate = nil
+		mu.Unlock()
+	})
+
+	t.Run("kid desconhecido busca uma vez", func(t *testing.T) {
+		// aguarda o fim da atualização anterior
+		serve(verifier, "Bearer "+oldToken)
+		for {
+			verifier.remoteKeys.mu.Lock()
+			fetching := verifier.remoteKeys.fetching
+			verifier.remoteKeys.mu.Unlock()
+			if fetching == nil {
+				break
+			}
+			<-fetching
+		}
+
+		mu.Lock()
+		issuer = New("", WithECDSAPublicKey(&oldKey.PublicKey), WithECDSAPrivateKey(newKey))
+		newToken, _ := issuer.Sign(testClaims{Role: "user"}, time.Hour)
+		mu.Unlock()
+		expire(time.Minute)
+		before := count()
+
+		var wg sync.WaitGroup
+		codes := make([]int, 20)
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i], _ = serve(verifier, "Bearer "+newToken)
+			}()
+		}
+		wg.Wait()
+
+		for _, code := range codes {
+			if code != http.StatusOK {
+				t.Fatalf("Middleware() = %d, want 200", code)
+			}
+		}
+		if n := count() - before; n != 1 {
+			t.Errorf("buscas do JWKS = %d, want 1", n)
+		}
+
+		other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		unknown, _ := New("", WithECDSAPrivateKey(other)).Sign(testClaims{Role: "admin"}, time.Hour)
+		if code, _ := serve(verifier, "Bearer "+unknown); code != http.StatusUnauthorized {
+			t.Errorf("Middleware() com chave desconhecida = %d, want 401", code)
+		}
+		if n := count() - before; n != 1 {
+			t.Errorf("buscas do JWKS após kid desconhecido = %d, want 1 (limitadas a cada %s)", n, jwksMinRefetch)
+		}
+	})
+}
+
+func TestRemoteJWKSFailures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := New("", WithECDSAPrivateKey(key))
+
+	// token sem kid, verificado com todas as chaves do algoritmo
+	withoutKid, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"data": map[string]any{"role": "admin"},
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("intervalo padrão", func(t *testing.T) {
+		if a := New("", WithRemoteJWKS("http://jwks.invalido", 0)); a.remoteKeys.refresh != jwksDefaultRefresh {
+			t.Errorf("refresh = %s, want %s", a.remoteKeys.refresh, jwksDefaultRefresh)
+		}
+	})
+
+	t.Run("nova tentativa após falha", func(t *testing.T) {
+		var failing atomic.Bool
+		failing.Store(true)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failing.Load() {
+				http.Error(w, "indisponível", http.StatusServiceUnavailable)
+				return
+			}
+			issuer.JWKSHandler().ServeHTTP(w, r)
+		}))
+		defer server.Close()
+
+		verifier := New("", WithRemoteJWKS(server.URL, time.Hour))
+		if code, _ := serve(verifier, "Bearer "+withoutKid); code != http.StatusUnauthorized {
+			t.Fatalf("Middleware() com JWKS indisponível = %d, want 401", code)
+		}
+
+		// antes do intervalo de atualização, passado o backoff
+		failing.Store(false)
+		verifier.remoteKeys.mu.Lock()
+		verifier.remoteKeys.retryAt = time.Now()
+		verifier.remoteKeys.mu.Unlock()
+		if code, role := serve(verifier, "Bearer "+withoutKid); code != http.StatusOK || role != "admin" {
+			t.Errorf("Middleware() após nova tentativa = %d, %q, want 200, \"admin\"", code, role)
+		}
+	})
+
+	t.Run("documento acima do limite", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			issuer.JWKSHandler().ServeHTTP(rec, r)
+			// chaves válidas seguidas de um campo que ultrapassa o limite
+			body := strings.TrimSuffix(strings.TrimSpace(rec.Body.String()), "}")
+			w.Write([]byte(body + `,"x":"` + strings.Repeat("a", jwksMaxBody) + `"}`))
+		}))
+		defer server.Close()
+
+		verifier := New("", WithRemoteJWKS(server.URL, time.Hour))
+		if code, _ := serve(verifier, "Bearer "+withoutKid); code != http.StatusUnauthorized {
+			t.Errorf("Middleware() com JWKS acima do limite = %d, want 401", code)
+		}
+	})
+}
+
+func TestDecodeJWKRejectsMismatchedAlg(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		key   any
+		alg   string
+		valid bool
+	}{
+		{name: "RS256 em RSA", key: &rsaKey.PublicKey, alg: "RS256", valid: true},
+		{name: "PS256 em RSA", key: &rsaKey.PublicKey, alg: "PS256", valid: true},
+		{name: "ES256 em P-256", key: &ecKey.PublicKey, alg: "ES256", valid: true},
+		{name: "EdDSA em Ed25519", key: edKey, alg: "EdDSA", valid: true},
+		{name: "HS256 em RSA", key: &rsaKey.PublicKey, alg: "HS256"},
+		{name: "ES256 em RSA", key: &rsaKey.PublicKey, alg: "ES256"},
+		{name: "ES384 em P-256", key: &ecKey.PublicKey, alg: "ES384"},
+		{name: "RS256 em EC", key: &ecKey.PublicKey, alg: "RS256"},
+		{name: "ES256 em Ed25519", key: edKey, alg: "ES256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := encodeJWK(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k.Alg = tt.alg
+			if _, err := decodeJWK(k); (err == nil) != tt.valid {
+				t.Errorf("decodeJWK() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestHMACKeyringRotation(t *testing.T) {
+	legacy := New("v0")
+	before := New("v0", WithHMACKeyring("v1", map[string]string{"v1": "secret-1"}))
+	after := New("v0", WithHMACKeyring("v2", map[string]string{"v1": "secret-1", "v2": "secret-2"}))
+	retired := New("", WithHMACKeyring("v2", map[string]string{"v2": "secret-2"}))
+
+	legacyToken, err := legacy.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1Token, err := before.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2Token, err := after.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier *Authenticator
+		token    string
+		expected int
+	}{
+		{"Token without kid on legacy secret", after, legacyToken, http.StatusOK},
+		{"Previous kid still accepted", after, v1Token, http.StatusOK},
+		{"Current kid accepted", after, v2Token, http.StatusOK},
+		{"Retired kid rejected", retired, v1Token, http.StatusUnauthorized},
+		{"Token without kid after legacy removal", retired, legacyToken, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := serve(tt.verifier, "Bearer "+tt.token); code != tt.expected {
+				t.Errorf("Middleware() = %d, want %d", code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	ctx := context.Background()
+	a := New("secret", WithRefreshTokens(NewMemoryRefreshStore(), time.Minute, time.Hour))
+
+	first, err := a.IssueTokenPair(ctx, testClaims{UserID: 1, Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := a.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if code, role := serve(a, "Bearer "+second.AccessToken); code != http.StatusOK || role != "admin" {
+		t.Errorf("Middleware() = %d, %q, want 200, \"admin\"", code, role)
+	}
+
+	if _, err := a.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() reused error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := a.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after family revocation error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := a.Refresh(ctx, "desconhecido"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() unknown error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+// reuseOnSaveStore reapresenta o token trocado antes de gravar o novo par,
+// simulando uma reutilização concorrente entre MarkUsed e Save
+type reuseOnSaveStore struct {
+	*MemoryRefreshStore
+	reuse func()
+}
+
+func (s *reuseOnSaveStore) Save(ctx context.Context, token RefreshToken) error {
+	if reuse := s.reuse; reuse != nil {
+		s.reuse = nil
+		reuse()
+	}
+	return s.MemoryRefreshStore.Save(ctx, token)
+}
+
+func TestRefreshConcurrentReuse(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reutilização durante a troca", func(t *testing.T) {
+		store := &reuseOnSaveStore{MemoryRefreshStore: NewMemoryRefreshStore()}
+		a := New("secret", WithRefreshTokens(store, time.Minute, time.Hour))
+		first, err := a.IssueTokenPair(ctx, testClaims{UserID: 1, Role: "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store.reuse = func() {
+			if _, err := a.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+				t.Errorf("Refresh() reused error = %v, want ErrRefreshTokenReused", err)
+			}
+		}
+		if _, err := a.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("Refresh() error = %v, want ErrRefreshTokenReused", err)
+		}
+		for _, token := range store.tokens {
+			if !token.Revoked {
+				t.Errorf("token %s não revogado após a reutilização", token.ID)
+			}
+		}
+	})
+
+	t.Run("trocas concorrentes", func(t *testing.T) {
+		a := New("secret", WithRefreshTokens(NewMemoryRefreshStore(), time.Minute, time.Hour))
+		first, err := a.IssueTokenPair(ctx, testClaims{UserID: 1, Role: "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			pairs []TokenPair
+		)
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if pair, err := a.Refresh(ctx, first.RefreshToken); err == nil {
+					mu.Lock()
+					pairs = append(pairs, pair)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		// as trocas perdedoras revogam a família, inclusive o par da vencedora
+		if len(pairs) > 1 {
+			t.Fatalf("Refresh() concorrente emitiu %d pares, want no máximo 1", len(pairs))
+		}
+		for _, pair := range pairs {
+			if _, err := a.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Refresh() do par emitido error = %v, want ErrInvalidRefreshToken", err)
+			}
+		}
+	})
+}
+
+func TestMemoryRefreshStorePurge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryRefreshStore()
+	a := New("secret",
+		WithRefreshTokens(store, time.Minute, time.Hour),
+		WithClock(func() time.Time { return now }),
+	)
+
+	first, err := a.IssueTokenPair(ctx, testClaims{UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := a.IssueTokenPair(ctx, testClaims{UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Find(ctx, hashToken(first.RefreshToken)); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("Find() de token expirado error = %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	a := New("secret", WithRefreshTokens(NewMemoryRefreshStore(), time.Minute, time.Hour))
+	pair, err := a.IssueTokenPair(context.Background(), testClaims{Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Valid refresh token", `{"refresh_token":"` + pair.RefreshToken + `"}`, http.StatusOK},
+		{"Reused refresh token", `{"refresh_token":"` + pair.RefreshToken + `"}`, http.StatusUnauthorized},
+		{"Missing refresh token", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			a.RefreshHandler().ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("RefreshHandler() = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	ctx := context.Background()
+	a := New("secret", WithRevoker(NewMemoryRevoker(), "user_id"))
+
+	logout, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSession, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherUser, err := a.Sign(testClaims{UserID: 2, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+logout)
+	rec := httptest.NewRecorder()
+	a.LogoutHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("LogoutHandler() = %d, want 204", rec.Code)
+	}
+
+	if code, _ := serve(a, "Bearer "+logout); code != http.StatusUnauthorized {
+		t.Errorf("Middleware() after logout = %d, want 401", code)
+	}
+	if code, _ := serve(a, "Bearer "+otherSession); code != http.StatusOK {
+		t.Errorf("Middleware() other session = %d, want 200", code)
+	}
+
+	if err := a.RevokeSubject(ctx, "1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := serve(a, "Bearer "+otherSession); code != http.StatusUnauthorized {
+		t.Errorf("Middleware() after RevokeSubject = %d, want 401", code)
+	}
+	if code, _ := serve(a, "Bearer "+otherUser); code != http.StatusOK {
+		t.Errorf("Middleware() other user = %d, want 200", code)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	a := New("secret", WithScopesClaim("scopes"))
+
+	token, err := a.Sign(fieldsClaims{
+		"role":   "gerente",
+		"scopes": "pedidos:ler pedidos:escrever",
+		"tenant": "acme",
+		"groups": []string{"vendas", "compras"},
+		"filial": map[string]any{"id": 1},
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		authorize func(http.Handler) http.Handler
+		expected  int
+	}{
+		{"Role granted", a.RequireRoles("admin", "gerente"), http.StatusOK},
+		{"Role denied", a.RequireRoles("admin"), http.StatusForbidden},
+		{"All scopes granted", a.RequireScopes("pedidos:ler", "pedidos:escrever"), http.StatusOK},
+		{"Missing scope", a.RequireScopes("pedidos:ler", "pedidos:excluir"), http.StatusForbidden},
+		{"Any rule", a.RequireAny(a.HasRole("admin"), a.HasScope("pedidos:ler")), http.StatusOK},
+		{"All rules", a.RequireAll(a.HasRole("gerente"), ClaimEquals("tenant", "outro")), http.StatusForbidden},
+		{"Predicate", a.Require(func(claims map[ContextValue]any) bool { return claims["tenant"] == "acme" }), http.StatusOK},
+		{"Claim list", a.RequireAll(ClaimEquals("groups", []any{"vendas", "compras"})), http.StatusOK},
+		{"Claim list against string", a.RequireAll(ClaimEquals("groups", "vendas")), http.StatusForbidden},
+		{"Claim object", a.RequireAll(ClaimEquals("filial", map[string]any{"id": float64(1)})), http.StatusOK},
+		{"Claim object mismatch", a.RequireAll(ClaimEquals("filial", map[string]any{"id": float64(2)})), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := a.Middleware()(tt.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("authorization = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequireWithoutArguments(t *testing.T) {
+	a := New("secret")
+	token, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		authorize func(http.Handler) http.Handler
+	}{
+		{"RequireRoles", a.RequireRoles()},
+		{"RequireScopes", a.RequireScopes()},
+		{"RequireAny", a.RequireAny()},
+		{"RequireAll", a.RequireAll()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := a.Middleware()(tt.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("%s() = %d, want 403", tt.name, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRegisteredClaimsValidation(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	issuer := New("secret", WithIssuer("auth"), WithClock(clock))
+	sign := func(expireIn time.Duration, opts ...SignOption) string {
+		token, err := issuer.Sign(testClaims{Role: "admin"}, expireIn, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	financeiro := New("secret", WithIssuer("auth"), WithExpectedAudience("financeiro"), WithClock(clock))
+
+	tests := []struct {
+		name     string
+		verifier *Authenticator
+		token    string
+		expected int
+	}{
+		{"Expected audience", financeiro, sign(time.Hour, WithAudience("financeiro")), http.StatusOK},
+		{"Other audience", financeiro, sign(time.Hour, WithAudience("estoque")), http.StatusUnauthorized},
+		{"Missing audience", financeiro, sign(time.Hour), http.StatusUnauthorized},
+		{"Other issuer", New("secret", WithIssuer("outro"), WithClock(clock)), sign(time.Hour), http.StatusUnauthorized},
+		{"Not yet valid", financeiro, sign(time.Hour, WithAudience("financeiro"), WithNotBefore(now.Add(time.Minute))), http.StatusUnauthorized},
+		{"Not before within leeway", New("secret", WithLeeway(2*time.Minute), WithClock(clock)), sign(time.Hour, WithNotBefore(now.Add(time.Minute))), http.StatusOK},
+		{"Expired", New("secret", WithClock(clock)), sign(-time.Minute), http.StatusUnauthorized},
+		{"Expired within leeway", New("secret", WithLeeway(2*time.Minute), WithClock(clock)), sign(-time.Minute), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := serve(tt.verifier, "Bearer "+tt.token); code != tt.expected {
+				t.Errorf("Middleware() = %d, want %d", code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFailureReasons(t *testing.T) {
+	var reason error
+	a := New("secret",
+		WithRevoker(NewMemoryRevoker(), ""),
+		WithBasicAuthValidator(func(clientID, secret string) bool { return secret == "ok" }),
+		WithFailureHook(func(r *http.Request, err error) { reason = err }),
+	)
+
+	valid, err := a.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := a.Sign(testClaims{Role: "admin"}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := New("other").Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := a.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RevokeToken(context.Background(), revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      error
+	}{
+		{"Missing token", "", ErrMissingToken},
+		{"Malformed token", "Bearer abc.def", ErrMalformedToken},
+		{"Expired token", "Bearer " + expired, ErrTokenExpired},
+		{"Bad signature", "Bearer " + forged, ErrInvalidSignature},
+		{"Revoked token", "Bearer " + revoked, ErrTokenRevoked},
+		{"Basic rejected", "Basic " + base64.StdEncoding.EncodeToString([]byte("client:wrong")), ErrBasicAuthRejected},
+		{"Valid token", "Bearer " + valid, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason = nil
+			handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tt.expected == nil {
+				if rec.Code != http.StatusOK || reason != nil {
+					t.Errorf("Middleware() = %d, %v, want 200", rec.Code, reason)
+				}
+				return
+			}
+			if !errors.Is(reason, tt.expected) {
+				t.Errorf("failure reason = %v, want %v", reason, tt.expected)
+			}
+			if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Middleware() = %d, WWW-Authenticate %q, want 401 with header", rec.Code, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+type typedTestClaims struct {
+	UserID int64    `json:"user_id"`
+	Role   string   `json:"role"`
+	Tags   []string `json:"tags"`
+}
+
+func TestTypedClaimsRoundTrip(t *testing.T) {
+	a := NewTyped[typedTestClaims]("secret")
+	want := typedTestClaims{UserID: 9007199254740993, Role: "admin", Tags: []string{"a", "b"}}
+
+	token, err := a.Sign(want, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got typedTestClaims
+	var ok bool
+	handler := a.Middleware()(a.RequireRoles("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = GetClaims[typedTestClaims](r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !ok {
+		t.Fatalf("Middleware() = %d, ok %v, want 200 and claims", rec.Code, ok)
+	}
+	if got.UserID != want.UserID || got.Role != want.Role || len(got.Tags) != 2 {
+		t.Errorf("GetClaims() = %+v, want %+v", got, want)
+	}
+
+	if code, role := serve(a.Authenticator, "Bearer "+token); code != http.StatusOK || role != "admin" {
+		t.Errorf("untyped Middleware() = %d, %q, want 200, \"admin\"", code, role)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	a := New("secret", WithAPIKeys(store))
+	ctx := context.Background()
+
+	key, record, err := GenerateAPIKey("cgi_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Data = map[ContextValue]any{"role": "integracao"}
+	record.Scopes = []string{"pedidos:ler"}
+	if err := store.Save(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+
+	expiredKey, expired, err := GenerateAPIKey("cgi_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := store.Save(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := a.Middleware("role")(a.RequireScopes("pedidos:ler")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected int
+	}{
+		{name: "header X-API-Key", header: "X-API-Key", value: key, expected: http.StatusOK},
+		{name: "Authorization ApiKey", header: "Authorization", value: "ApiKey " + key, expected: http.StatusOK},
+		{name: "segredo incorreto", header: "X-API-Key", value: key[:len(key)-1] + "x", expected: http.StatusUnauthorized},
+		{name: "chave desconhecida", header: "X-API-Key", value: "cgi_test_000000000000_abc", expected: http.StatusUnauthorized},
+		{name: "chave expirada", header: "X-API-Key", value: expiredKey, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Middleware() = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsTokenHandler(t *testing.T) {
+	store := NewMemoryClientStore()
+	a := New("secret", WithClientCredentials(store, 5*time.Minute))
+
+	secret, client, err := GenerateClient("erp", "pedidos:ler", "pedidos:escrever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("erp:"+secret))
+
+	tests := []struct {
+		name          string
+		authorization string
+		form          string
+		expected      int
+		errorCode     string
+		scope         string
+	}{
+		{name: "client_secret_basic", authorization: basic, form: "grant_type=client_credentials&scope=pedidos:ler", expected: http.StatusOK, scope: "pedidos:ler"},
+		{name: "client_secret_post", form: "grant_type=client_credentials&client_id=erp&client_secret=" + secret, expected: http.StatusOK, scope: "pedidos:ler pedidos:escrever"},
+		{name: "segredo incorreto", form: "grant_type=client_credentials&client_id=erp&client_secret=x", expected: http.StatusUnauthorized, errorCode: "invalid_client"},
+		{name: "sem credenciais", form: "grant_type=client_credentials", expected: http.StatusUnauthorized, errorCode: "invalid_client"},
+		{name: "escopo não permitido", authorization: basic, form: "grant_type=client_credentials&scope=admin", expected: http.StatusBadRequest, errorCode: "invalid_scope"},
+		{name: "grant não suportado", authorization: basic, form: "grant_type=password", expected: http.StatusBadRequest, errorCode: "unsupported_grant_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			a.TokenHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Fatalf("TokenHandler() = %d, want %d: %s", rec.Code, tt.expected, rec.Body)
+			}
+
+			var body struct {
+				AccessToken string `json:"access_token"`
+				Scope       string `json:"scope"`
+				Error       string `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error != tt.errorCode {
+				t.Errorf("error = %q, want %q", body.Error, tt.errorCode)
+			}
+			if tt.expected != http.StatusOK {
+				return
+			}
+			if body.Scope != tt.scope {
+				t.Errorf("scope = %q, want %q", body.Scope, tt.scope)
+			}
+
+			handler := a.Middleware("client_id")(a.RequireScopes("pedidos:ler")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+body.AccessToken)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("Middleware() = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestIntrospectionAndRevocation(t *testing.T) {
+	clients := NewMemoryClientStore()
+	a := New("secret",
+		WithClientCredentials(clients, 5*time.Minute),
+		WithRevoker(NewMemoryRevoker(), ""),
+	)
+
+	secret, client, err := GenerateClient("gateway", "pedidos:ler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clients.Save(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	token, err := a.Sign(testClaims{UserID: 7, Role: "admin"}, time.Hour, WithSubject("7"), WithAudience("gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClient, err := a.Sign(fieldsClaims{"client_id": "relatorios"}, time.Hour, WithSubject("relatorios"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(handler http.Handler, clientSecret, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("gateway", clientSecret)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	introspect := func(token string) map[string]any {
+		rec := post(a.IntrospectionHandler(), secret, "token="+token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("IntrospectionHandler() = %d, want 200", rec.Code)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	if rec := post(a.IntrospectionHandler(), "errado", "token="+token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("IntrospectionHandler() sem cliente válido = %d, want 401", rec.Code)
+	}
+
+	body := introspect(token)
+	if body["active"] != true || body["sub"] != "7" || body["data"].(map[string]any)["role"] != "admin" {
+		t.Errorf("IntrospectionHandler() = %v, want token ativo", body)
+	}
+
+	if rec := post(a.RevocationHandler(), secret, "token="+otherClient); rec.Code != http.StatusOK {
+		t.Fatalf("RevocationHandler() com token de outro cliente = %d, want 200", rec.Code)
+	}
+	if body := introspect(otherClient); body["active"] != true {
+		t.Errorf("IntrospectionHandler() após revogação por outro cliente = %v, want token ativo", body)
+	}
+
+	if rec := post(a.RevocationHandler(), secret, "token="+token); rec.Code != http.StatusOK {
+		t.Fatalf("RevocationHandler() = %d, want 200", rec.Code)
+	}
+	if body := introspect(token); body["active"] != false || len(body) != 1 {
+		t.Errorf("IntrospectionHandler() após revogação = %v, want {active: false}", body)
+	}
+
+	if rec := post(a.RevocationHandler(), secret, "token=a.b.c"); rec.Code != http.StatusOK {
+		t.Errorf("RevocationHandler() com token inválido = %d, want 200", rec.Code)
+	}
+}
+
+func TestSessionCookieAndCSRF(t *testing.T) {
+	a := New("secret", WithCookieName("SESSION"))
+
+	rec := httptest.NewRecorder()
+	token, err := a.SignSession(rec, testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("SignSession() gravou %d cookies, want 2", len(cookies))
+	}
+	session, csrf := cookies[0], cookies[1]
+	if session.Value != token || !session.HttpOnly || !session.Secure || session.MaxAge != 3600 || session.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie de sessão = %+v", session)
+	}
+	if csrf.Name != "SESSION_CSRF" || csrf.HttpOnly {
+		t.Errorf("cookie csrf = %+v", csrf)
+	}
+
+	handler := a.Middleware("role")(a.CSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		name          string
+		method        string
+		withCookie    bool
+		authorization string
+		csrfHeader    string
+		expected      int
+	}{
+		{name: "GET com cookie", method: http.MethodGet, withCookie: true, expected: http.StatusOK},
+		{name: "POST com cookie e header", method: http.MethodPost, withCookie: true, csrfHeader: csrf.Value, expected: http.StatusOK},
+		{name: "POST com cookie sem header", method: http.MethodPost, withCookie: true, expected: http.StatusForbidden},
+		{name: "POST com cookie e header forjado", method: http.MethodPost, withCookie: true, csrfHeader: csrfToken("outra-sessao"), expected: http.StatusForbidden},
+		{name: "POST com Bearer", method: http.MethodPost, authorization: "Bearer " + token, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.withCookie {
+				req.AddCookie(&http.Cookie{Name: "SESSION", Value: token})
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.csrfHeader != "" {
+				req.Header.Set(CSRFHeader, tt.csrfHeader)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("CSRF() = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+
+	t.Run("POST com cookie do v1", func(t *testing.T) {
+		v1 := New("secret", WithV1Compat(V1Compat{SecretKey: "secret"}))
+		handler := v1.Middleware("role")(v1.CSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+		for _, csrfHeader := range []string{"", csrfToken(token)} {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "CIMSSESSIONTOKEN", Value: token})
+			if csrfHeader != "" {
+				req.Header.Set(CSRFHeader, csrfHeader)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			expected := http.StatusForbidden
+			if csrfHeader != "" {
+				expected = http.StatusOK
+			}
+			if rec.Code != expected {
+				t.Errorf("CSRF() com header %q = %d, want %d", csrfHeader, rec.Code, expected)
+			}
+		}
+	})
+
+	rec = httptest.NewRecorder()
+	if err := a.ClearSessionCookie(rec); err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge != -1 || cookie.Value != "" {
+			t.Errorf("ClearSessionCookie() = %+v, want cookie expirado", cookie)
+		}
+	}
+}
+
+func TestSlidingSession(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	now := start
+	a := New("secret", WithSlidingSession(0.5, 90*time.Minute), WithClock(func() time.Time { return now }))
+
+	token, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renew := func(token string) string {
+		t.Helper()
+		handler := a.Middleware("role")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Middleware() = %d, want 200", rec.Code)
+		}
+		return rec.Header().Get(RenewedTokenHeader)
+	}
+
+	now = start.Add(10 * time.Minute)
+	if renewed := renew(token); renewed != "" {
+		t.Errorf("token renovado antes do limiar")
+	}
+
+	now = start.Add(40 * time.Minute)
+	renewed := renew(token)
+	if renewed == "" {
+		t.Fatal("token não renovado após o limiar")
+	}
+	claims, err := a.parseJWT(renewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.ExpiresAt.Equal(start.Add(90*time.Minute)) || !claims.AuthTime.Equal(start) || claims.Data["role"] != "admin" {
+		t.Errorf("token renovado = exp %v, auth_time %v, data %v", claims.ExpiresAt, claims.AuthTime, claims.Data)
+	}
+
+	now = start.Add(80 * time.Minute)
+	if again := renew(renewed); again != "" {
+		t.Errorf("token renovado além da duração máxima da sessão")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	a := New("secret")
+	limiter := a.RateLimit(NewMemoryRateLimitStore(), Limit{Requests: 2, Window: time.Minute},
+		WithRoleLimit("parceiro", Limit{Requests: 5, Window: time.Minute}),
+		WithRoleLimit("admin", Limit{}),
+	)
+	handler := a.Middleware()(limiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tokenFor := func(userID int64, role string) string {
+		token, err := a.Sign(testClaims{UserID: userID, Role: role}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		allowed       int
+	}{
+		{name: "limite padrão", authorization: tokenFor(1, "user"), allowed: 2},
+		{name: "limite por papel", authorization: tokenFor(2, "parceiro"), allowed: 5},
+		{name: "papel sem limite", authorization: tokenFor(3, "admin"), allowed: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := 0
+			var last *httptest.ResponseRecorder
+			for range 10 {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", tt.authorization)
+				last = httptest.NewRecorder()
+				handler.ServeHTTP(last, req)
+				if last.Code == http.StatusOK {
+					allowed++
+				}
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("RateLimit() permitiu %d requisições, want %d", allowed, tt.allowed)
+			}
+			if tt.allowed < 10 && (last.Code != http.StatusTooManyRequests || last.Header().Get("Retry-After") == "" || last.Header().Get("RateLimit-Remaining") != "0") {
+				t.Errorf("RateLimit() = %d, headers %v, want 429 com Retry-After", last.Code, last.Header())
+			}
+		})
+	}
+}
+
+func TestPostgresRateLimitStore(t *testing.T) {
+	upsert := `INSERT INTO auth_rate_limits AS t (key, tat) VALUES ($1, $2::bigint + $3::bigint)
+		ON CONFLICT (key) DO UPDATE SET tat = GREATEST(t.tat, $2::bigint) + $3::bigint
+		WHERE GREATEST(t.tat, $2::bigint) + $3::bigint - $2::bigint <= $4::bigint
+		RETURNING tat`
+	limit := PerMinute(60)
+
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		allowed bool
+	}{
+		{
+			name: "permitida",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(upsert).
+					WithArgs("user_id:1", sqlmock.AnyArg(), int64(1_000_000), int64(60_000_000)).
+					WillReturnRows(sqlmock.NewRows([]string{"tat"}).AddRow(time.Now().Add(time.Second).UnixMicro()))
+			},
+			allowed: true,
+		},
+		{
+			name: "negada",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(upsert).
+					WithArgs("user_id:1", sqlmock.AnyArg(), int64(1_000_000), int64(60_000_000)).
+					WillReturnRows(sqlmock.NewRows([]string{"tat"}))
+				mock.ExpectQuery(`SELECT tat FROM auth_rate_limits WHERE key = $1`).
+					WithArgs("user_id:1").
+					WillReturnRows(sqlmock.NewRows([]string{"tat"}).AddRow(time.Now().Add(time.Minute).UnixMicro()))
+			},
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			result, err := NewPostgresRateLimitStore(db, "auth_rate_limits").Allow(context.Background(), "user_id:1", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != tt.allowed || (!tt.allowed && result.RetryAfter <= 0) {
+				t.Errorf("Allow() = %+v, want Allowed %v", result, tt.allowed)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBasicAuthLockout(t *testing.T) {
+	start := time.Now()
+	now := start
+	var events []LockoutEventType
+	a := New("secret",
+		WithClock(func() time.Time { return now }),
+		WithBasicAuthValidator(func(clientID, secret string) bool {
+			return clientID == "erp" && secret == "certo"
+		}),
+		WithBasicAuthLockout(NewMemoryLockoutStore(), LockoutPolicy{
+			MaxAttempts:  3,
+			LockDuration: time.Minute,
+			OnEvent: func(e LockoutEvent) {
+				if e.Key == "client:erp" {
+					events = append(events, e.Type)
+				}
+			},
+		}),
+	)
+
+	var reason error
+	a.failureHook = func(r *http.Request, err error) { reason = err }
+	basic := func(secret string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("erp:"+secret))
+	}
+
+	for range 3 {
+		if code, _ := serve(a, basic("errado")); code != http.StatusUnauthorized {
+			t.Fatalf("Middleware() = %d, want 401", code)
+		}
+	}
+
+	reason = nil
+	if code, _ := serve(a, basic("certo")); code != http.StatusUnauthorized || !errors.Is(reason, ErrCredentialsLocked) {
+		t.Errorf("Middleware() durante bloqueio = %d, %v, want 401 com ErrCredentialsLocked", code, reason)
+	}
+
+	now = start.Add(61 * time.Second)
+	if code, _ := serve(a, basic("certo")); code != http.StatusOK {
+		t.Errorf("Middleware() após o bloqueio = %d, want 200", code)
+	}
+
+	want := []LockoutEventType{LockoutFailure, LockoutFailure, LockoutFailure, LockoutLocked, LockoutRejected, LockoutReset}
+	if !slices.Equal(events, want) {
+		t.Errorf("eventos = %v, want %v", events, want)
+	}
+}
+
+func TestBasicAuthLockoutConcurrent(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	a := New("secret",
+		WithBasicAuthValidator(func(clientID, secret string) bool {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			return false
+		}),
+		WithBasicAuthLockout(NewMemoryLockoutStore(), LockoutPolicy{MaxAttempts: 3}),
+	)
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("erp:errado"))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			serve(a, basic)
+		})
+	}
+	wg.Wait()
+
+	if calls != 3 {
+		t.Errorf("validador chamado %d vezes em tentativas concorrentes, want 3", calls)
+	}
+}
+
+func TestV1Compat(t *testing.T) {
+	v1Token := func(secret string, registered jwt.RegisteredClaims) string {
+		registered.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+		claims := struct {
+			Data map[string]any `json:"data"`
+			jwt.RegisteredClaims
+		}{Data: map[string]any{"role": "admin"}, RegisteredClaims: registered}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	var flavor TokenFlavor
+	compat := func(allowBasic bool) *Authenticator {
+		return New("secret-v2",
+			WithIssuer("cgi"),
+			WithV1Compat(V1Compat{
+				SecretKey:             "secret-v1",
+				AllowUnvalidatedBasic: allowBasic,
+				OnAuthenticated:       func(r *http.Request, f TokenFlavor) { flavor = f },
+			}),
+		)
+	}
+	a := compat(false)
+	v2Token, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("erp:qualquer"))
+
+	tests := []struct {
+		name          string
+		a             *Authenticator
+		authorization string
+		cookie        string
+		expected      int
+		flavor        TokenFlavor
+	}{
+		{name: "token v2", a: a, authorization: "Bearer " + v2Token, expected: http.StatusOK, flavor: FlavorV2},
+		{name: "token v1", a: a, authorization: "Bearer " + v1Token("secret-v1", jwt.RegisteredClaims{}), expected: http.StatusOK, flavor: FlavorV1},
+		{name: "token v1 no cookie do v1", a: a, cookie: v1Token("secret-v1", jwt.RegisteredClaims{}), expected: http.StatusOK, flavor: FlavorV1},
+		{name: "token v1 com chave errada", a: a, authorization: "Bearer " + v1Token("outra", jwt.RegisteredClaims{}), expected: http.StatusUnauthorized},
+		{name: "token com claims do v2 de outro emissor", a: a, authorization: "Bearer " + v1Token("secret-v1", jwt.RegisteredClaims{Issuer: "outro", ID: "x"}), expected: http.StatusUnauthorized},
+		{name: "basic sem validação rejeitado", a: a, authorization: basic, expected: http.StatusUnauthorized},
+		{name: "basic sem validação permitido", a: compat(true), authorization: basic, expected: http.StatusOK, flavor: FlavorV1Basic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flavor = ""
+			var fromContext TokenFlavor
+			handler := tt.a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext, _ = FlavorFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "CIMSSESSIONTOKEN", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected || flavor != tt.flavor || fromContext != tt.flavor {
+				t.Errorf("Middleware() = %d, flavor %q/%q, want %d, %q", rec.Code, flavor, fromContext, tt.expected, tt.flavor)
+			}
+		})
+	}
+}
+
+func TestMultiTenant(t *testing.T) {
+	keys := NewMemoryAPIKeyStore()
+	apiKey, record, err := GenerateAPIKey("cgi_acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Save(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	basicAuth := WithBasicAuthValidator(func(clientID, secret string) bool {
+		return clientID == "erp" && secret == "acme"
+	})
+
+	tenants := StaticTenants{
+		"acme":   New("secret-acme", WithIssuer("https://acme"), WithAllowedIssuers("https://sso.acme"), WithCookieName("SESSION"), WithAPIKeys(keys), basicAuth),
+		"globex": New("secret-globex"),
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("erp:acme"))
+
+	byClaim := NewMultiTenant(tenants)
+	byCookie := NewMultiTenant(tenants, WithTenantResolvers(TenantFromClaim("tenant_id", WithCookieName("SESSION"))))
+	byHeader := NewMultiTenant(tenants, WithTenantResolvers(TenantFromHost("app.cgi.com.br"), TenantFromHeader("X-Tenant-ID")))
+
+	acmeToken, err := byClaim.Sign(context.Background(), "acme", testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssoToken, err := New("secret-acme", WithIssuer("https://sso.acme")).Sign(fieldsClaims{"tenant_id": "acme"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutTenant, err := New("secret-acme", WithIssuer("https://acme")).Sign(testClaims{UserID: 2}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := New("secret-globex").Sign(fieldsClaims{"tenant_id": "acme"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		m      *MultiTenant
+		host   string
+		header string
+		token  string
+		cookie bool
+		// authorization substitui o header "Bearer <token>"
+		authorization string
+		expected      int
+		tenant        string
+	}{
+		{name: "tenant pelo claim", m: byClaim, token: acmeToken, expected: http.StatusOK, tenant: "acme"},
+		{name: "tenant pelo claim do cookie", m: byCookie, token: acmeToken, cookie: true, expected: http.StatusOK, tenant: "acme"},
+		{name: "cookie sem opção de credencial", m: byClaim, token: acmeToken, cookie: true, expected: http.StatusUnauthorized},
+		{name: "token forjado com chave de outro tenant", m: byClaim, token: forged, expected: http.StatusUnauthorized},
+		{name: "tenant pelo host", m: byHeader, host: "acme.app.cgi.com.br", token: ssoToken, expected: http.StatusOK, tenant: "acme"},
+		{name: "tenant pelo header", m: byHeader, header: "acme", token: acmeToken, expected: http.StatusOK, tenant: "acme"},
+		{name: "token de outro tenant", m: byHeader, header: "globex", token: acmeToken, expected: http.StatusUnauthorized},
+		{name: "token sem claim de tenant", m: byHeader, header: "acme", token: withoutTenant, expected: http.StatusUnauthorized},
+		{name: "basic do tenant", m: byHeader, header: "acme", authorization: basic, expected: http.StatusOK, tenant: "acme"},
+		{name: "basic em outro tenant", m: byHeader, header: "globex", authorization: basic, expected: http.StatusUnauthorized},
+		{name: "api key do tenant", m: byHeader, header: "acme", authorization: "ApiKey " + apiKey, expected: http.StatusOK, tenant: "acme"},
+		{name: "api key em outro tenant", m: byHeader, header: "globex", authorization: "ApiKey " + apiKey, expected: http.StatusUnauthorized},
+		{name: "basic sem resolver de header", m: byClaim, authorization: basic, expected: http.StatusUnauthorized},
+		{name: "tenant desconhecido", m: byHeader, header: "initech", token: acmeToken, expected: http.StatusUnauthorized},
+		{name: "sem tenant", m: byHeader, token: acmeToken, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tenant string
+			handler := tt.m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tenant, _ = TenantFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			switch {
+			case tt.authorization != "":
+				req.Header.Set("Authorization", tt.authorization)
+			case tt.cookie:
+				req.AddCookie(&http.Cookie{Name: "SESSION", Value: tt.token})
+			default:
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected || tenant != tt.tenant {
+				t.Errorf("Middleware() = %d, tenant %q, want %d, %q", rec.Code, tenant, tt.expected, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := New("secret")
+	token, err := a.Sign(testClaims{UserID: 1, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		credential string
+		reason     error
+		role       string
+	}{
+		{name: "bearer", credential: "Bearer " + token, role: "admin"},
+		{name: "token sem esquema", credential: token, role: "admin"},
+		{name: "sem credencial", credential: "", reason: ErrMissingToken},
+		{name: "assinatura inválida", credential: "Bearer " + token + "x", reason: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := a.Authenticate(context.Background(), tt.credential, "role")
+			if !errors.Is(err, tt.reason) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.reason)
+			}
+			if role, _ := GetFromContext[string](ctx, "role"); role != tt.role {
+				t.Errorf("Authenticate() role = %q, want %q", role, tt.role)
+			}
+		})
+	}
+}
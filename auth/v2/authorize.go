@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// claimsContextKey é a chave do contexto onde o [Authenticator.Middleware]
+// armazena todos os claims do token
+type claimsContextKey struct{}
+
+// Rule é uma regra de autorização avaliada sobre os claims do token.
+// Use as regras prontas ([Authenticator.HasRole], [Authenticator.HasScope],
+// [ClaimEquals]) ou escreva a sua:
+//
+//	sameTenant := func(claims map[auth.ContextValue]any) bool {
+//	    return claims["tenant"] == "acme"
+//	}
+type Rule func(claims map[ContextValue]any) bool
+
+// WithRolesClaim configura o claim que contém os papéis do usuário.
+// O valor pode ser uma string ou uma lista de strings. O padrão é "role".
+func WithRolesClaim(name ContextValue) Option {
+	return func(a *Authenticator) {
+		a.rolesClaim = name
+	}
+}
+
+// WithScopesClaim configura o claim que contém os escopos concedidos.
+// O valor pode ser uma string separada por espaços (RFC 8693) ou uma lista
+// de strings. O padrão é "scope".
+func WithScopesClaim(name ContextValue) Option {
+	return func(a *Authenticator) {
+		a.scopesClaim = name
+	}
+}
+
+// ClaimsFromContext retorna todos os claims do token autenticado pelo
+// [Authenticator.Middleware], sem descriptografia.
+func ClaimsFromContext(ctx context.Context) (map[ContextValue]any, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(map[ContextValue]any)
+	return claims, ok
+}
+
+// HasRole retorna uma [Rule] satisfeita quando o usuário possui o papel informado.
+func (a *Authenticator) HasRole(role string) Rule {
+	return func(claims map[ContextValue]any) bool {
+		return slices.Contains(claimValues(claims[a.rolesClaim]), role)
+	}
+}
+
+// HasScope retorna uma [Rule] satisfeita quando o token concede o escopo informado.
+func (a *Authenticator) HasScope(scope string) Rule {
+	return func(claims map[ContextValue]any) bool {
+		return slices.Contains(claimValues(claims[a.scopesClaim]), scope)
+	}
+}
+
+// ClaimEquals retorna uma [Rule] satisfeita quando o claim possui o valor informado.
+// Números são comparados como float64, formato em que chegam após a decodificação do JWT;
+// listas e objetos são comparados em profundidade, como []any e map[string]any.
+func ClaimEquals(field ContextValue, value any) Rule {
+	return func(claims map[ContextValue]any) bool {
+		return reflect.DeepEqual(claims[field], value)
+	}
+}
+
+// RequireRoles retorna um middleware que exige ao menos um dos papéis informados.
+// Deve ser usado após [Authenticator.Middleware]. Sem nenhum papel, nega todas
+// as requisições, como [Authenticator.RequireAny].
+//
+//	r.With(a.RequireRoles("admin", "gerente")).Delete("/pedidos/{id}", handler)
+func (a *Authenticator) RequireRoles(roles ...string) func(next http.Handler) http.Handler {
+	rules := make([]Rule, len(roles))
+	for i, role := range roles {
+		rules[i] = a.HasRole(role)
+	}
+	return a.RequireAny(rules...)
+}
+
+// RequireScopes retorna um middleware que exige todos os escopos informados.
+// Deve ser usado após [Authenticator.Middleware]. Sem nenhum escopo, nega todas
+// as requisições, como [Authenticator.RequireAll].
+//
+//	r.With(a.RequireScopes("pedidos:ler", "pedidos:escrever")).Post("/pedidos", handler)
+func (a *Authenticator) RequireScopes(scopes ...string) func(next http.Handler) http.Handler {
+	rules := make([]Rule, len(scopes))
+	for i, scope := range scopes {
+		rules[i] = a.HasScope(scope)
+	}
+	return a.RequireAll(rules...)
+}
+
+// RequireAny retorna um middleware que exige que ao menos uma das regras seja
+// satisfeita. Sem nenhuma regra, nega todas as requisições com 403.
+//
+//	r.Use(a.RequireAny(a.HasRole("admin"), a.HasScope("relatorios:ler")))
+func (a *Authenticator) RequireAny(rules ...Rule) func(next http.Handler) http.Handler {
+	return a.Require(func(claims map[ContextValue]any) bool {
+		return slices.ContainsFunc(rules, func(rule Rule) bool { return rule(claims) })
+	})
+}
+
+// RequireAll retorna um middleware que exige que todas as regras sejam
+// satisfeitas. Sem nenhuma regra, nega todas as requisições com 403, para que
+// uma lista vazia por engano não libere a rota.
+//
+//	r.Use(a.RequireAll(a.HasRole("financeiro"), auth.ClaimEquals("tenant", "acme")))
+func (a *Authenticator) RequireAll(rules ...Rule) func(next http.Handler) http.Handler {
+	return a.Require(func(claims map[ContextValue]any) bool {
+		if len(rules) == 0 {
+			return false
+		}
+		for _, rule := range rules {
+			if !rule(claims) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Require retorna um middleware que autoriza a requisição quando a regra é satisfeita.
+// Requisições sem claims no contexto (sem [Authenticator.Middleware]) recebem 401;
+// requisições autenticadas que não satisfazem a regra recebem 403.
+func (a *Authenticator) Require(rule Rule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			claims, ok := ClaimsFromContext(request.Context())
+			if !ok {
+				a.unauthorized(response, request, ErrMissingToken)
+				return
+			}
+
+			if !rule(claims) {
+				writeError(response, http.StatusForbidden, "acesso negado")
+				return
+			}
+
+			next.ServeHTTP(response, request)
+		})
+	}
+}
+
+// claimValues normaliza um claim de papéis ou escopos para uma lista de strings
+func claimValues(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SignOption configura os claims registrados (RFC 7519) de um token emitido
+// por [Authenticator.Sign].
+type SignOption func(*jwt.RegisteredClaims)
+
+// WithSubject define o claim "sub", normalmente o identificador do usuário.
+//
+//	token, err := a.Sign(claims, time.Hour, auth.WithSubject("42"))
+func WithSubject(subject string) SignOption {
+	return func(c *jwt.RegisteredClaims) {
+		c.Subject = subject
+	}
+}
+
+// WithAudience define o claim "aud" com os serviços aos quais o token se destina.
+// Verificadores configurados com [WithExpectedAudience] rejeitam tokens de outro destino.
+//
+//	token, err := a.Sign(claims, time.Hour, auth.WithAudience("api-financeiro"))
+func WithAudience(audience ...string) SignOption {
+	return func(c *jwt.RegisteredClaims) {
+		c.Audience = audience
+	}
+}
+
+// WithNotBefore define o claim "nbf": o token só é aceito a partir do instante informado.
+func WithNotBefore(notBefore time.Time) SignOption {
+	return func(c *jwt.RegisteredClaims) {
+		c.NotBefore = jwt.NewNumericDate(notBefore)
+	}
+}
+
+// WithIssuer define o emissor dos tokens. [Authenticator.Sign] grava o valor
+// no claim "iss" e a verificação rejeita tokens de outro emissor.
+//
+//	a := auth.New("secret", auth.WithIssuer("https://auth.interno"))
+func WithIssuer(issuer string) Option {
+	return func(a *Authenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithAllowedIssuers aceita tokens de qualquer um dos emissores informados,
+// além do configurado em [WithIssuer], que continua sendo usado na emissão.
+// Útil quando um mesmo conjunto de chaves é compartilhado por mais de um
+// emissor, como os ambientes de um tenant.
+//
+//	a := auth.New("secret",
+//	    auth.WithIssuer("https://acme.cgisoftware.com.br"),
+//	    auth.WithAllowedIssuers("https://sso.acme.com"),
+//	)
+func WithAllowedIssuers(issuers ...string) Option {
+	return func(a *Authenticator) {
+		a.allowedIssuers = append(a.allowedIssuers, issuers...)
+	}
+}
+
+// WithExpectedAudience exige que o claim "aud" do token contenha o valor
+// informado, impedindo que tokens emitidos para outro serviço sejam aceitos.
+//
+//	a := auth.New("", auth.WithRemoteJWKS(url, time.Hour), auth.WithExpectedAudience("api-financeiro"))
+func WithExpectedAudience(audience string) Option {
+	return func(a *Authenticator) {
+		a.audience = audience
+	}
+}
+
+// WithLeeway define a tolerância de diferença de relógio aplicada a "exp",
+// "nbf" e "iat" na verificação.
+func WithLeeway(leeway time.Duration) Option {
+	return func(a *Authenticator) {
+		a.leeway = leeway
+	}
+}
+
+// WithClock substitui a fonte de tempo usada na emissão e na verificação
+// de tokens. Útil em testes.
+func WithClock(now func() time.Time) Option {
+	return func(a *Authenticator) {
+		a.clock = now
+	}
+}
+
+// now retorna o instante atual segundo o relógio configurado
+func (a *Authenticator) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock()
+}
+
+// parserOptions monta as validações de claims registrados configuradas
+func (a *Authenticator) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(a.now),
+	}
+	if a.leeway > 0 {
+		opts = append(opts, jwt.WithLeeway(a.leeway))
+	}
+	if a.issuer != "" && len(a.allowedIssuers) == 0 {
+		opts = append(opts, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		opts = append(opts, jwt.WithAudience(a.audience))
+	}
+	return opts
+}
+
+// checkIssuers valida o claim "iss" contra [WithAllowedIssuers], que o parser
+// do jwt não suporta por aceitar um único emissor
+func (a *Authenticator) checkIssuers(claims *internalClaims) error {
+	if len(a.allowedIssuers) == 0 {
+		return nil
+	}
+	if (a.issuer != "" && claims.Issuer == a.issuer) || slices.Contains(a.allowedIssuers, claims.Issuer) {
+		return nil
+	}
+	return fmt.Errorf("%w: emissor %q não permitido", ErrInvalidClaims, claims.Issuer)
+}
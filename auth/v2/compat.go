@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenFlavor identifica a origem da credencial aceita pelo [Authenticator.Middleware]
+// durante a migração do auth v1.
+type TokenFlavor string
+
+const (
+	// FlavorV2 indica um token emitido pelo v2 ou uma credencial validada pelo v2.
+	FlavorV2 TokenFlavor = "v2"
+	// FlavorV1 indica um token emitido por auth.GetSignToken (v1), sem jti, iat nem iss.
+	FlavorV1 TokenFlavor = "v1"
+	// FlavorV1Basic indica credenciais Basic aceitas sem validação, como no v1.
+	FlavorV1Basic TokenFlavor = "v1-basic"
+)
+
+// V1Compat configura a convivência com serviços ainda no auth v1
+// (auth.Initialize / AuthMiddlewareWithCrypt).
+type V1Compat struct {
+	// SecretKey é a chave usada no auth.Initialize. Vazio usa a secretKey de [New];
+	// com [WithHMACKeyring], informe-a explicitamente.
+	SecretKey string
+	// CookieName é o cookie de sessão do v1, lido quando presente. O padrão é
+	// "CIMSSESSIONTOKEN"; use "-" para não ler cookie do v1.
+	CookieName string
+	// AllowUnvalidatedBasic aceita credenciais Basic sem validação, como o v1,
+	// quando [WithBasicAuthValidator] não foi configurado. Desabilitado por
+	// padrão: mantenha apenas enquanto houver clientes que dependam disso.
+	AllowUnvalidatedBasic bool
+	// OnAuthenticated é chamado a cada requisição autenticada com o tipo de
+	// credencial usado, para acompanhar o progresso da migração.
+	OnAuthenticated func(r *http.Request, flavor TokenFlavor)
+}
+
+// flavorContextKey é a chave do contexto com o [TokenFlavor] da requisição
+type flavorContextKey struct{}
+
+// WithV1Compat faz o [Authenticator.Middleware] aceitar também tokens emitidos
+// pelo auth v1, permitindo migrar serviço a serviço:
+//
+//   - tokens do v1 (HS256, apenas data e exp) são aceitos com a chave do v1,
+//     sem as verificações de iss e aud, que o v1 não emite;
+//   - o cookie CIMSSESSIONTOKEN é lido como no v1;
+//   - com AllowUnvalidatedBasic, Basic sem validador é aceito como no v1.
+//
+// O tipo de credencial fica disponível em [FlavorFromContext].
+//
+// No sentido inverso, serviços v1 aceitam tokens do v2 assinados em HS256 com
+// a mesma chave.
+//
+//	a := auth.New("secret", auth.WithV1Compat(auth.V1Compat{
+//	    OnAuthenticated: func(r *http.Request, flavor auth.TokenFlavor) {
+//	        metrics.Inc("auth_flavor_" + string(flavor))
+//	    },
+//	}))
+func WithV1Compat(compat V1Compat) Option {
+	return func(a *Authenticator) {
+		if compat.CookieName == "" {
+			compat.CookieName = "CIMSSESSIONTOKEN"
+		}
+		a.v1 = &compat
+	}
+}
+
+// FlavorFromContext retorna o tipo de credencial da requisição autenticada.
+// Disponível apenas com [WithV1Compat].
+//
+//	if flavor, _ := auth.FlavorFromContext(r.Context()); flavor != auth.FlavorV2 {
+//	    slog.Info("cliente ainda no auth v1", "path", r.URL.Path)
+//	}
+func FlavorFromContext(ctx context.Context) (TokenFlavor, bool) {
+	flavor, ok := ctx.Value(flavorContextKey{}).(TokenFlavor)
+	return flavor, ok
+}
+
+// reportFlavor notifica o hook de migração com o tipo de credencial da requisição
+func (a *Authenticator) reportFlavor(r *http.Request, claims *internalClaims) {
+	if a.v1 != nil && a.v1.OnAuthenticated != nil {
+		a.v1.OnAuthenticated(r, claims.tokenFlavor())
+	}
+}
+
+// tokenFlavor retorna o tipo de credencial dos claims, v2 se não marcado
+func (c *internalClaims) tokenFlavor() TokenFlavor {
+	if c.flavor == "" {
+		return FlavorV2
+	}
+	return c.flavor
+}
+
+// v1Cookie retorna o cookie de sessão do v1, se configurado e presente
+func (a *Authenticator) v1Cookie(r *http.Request) (string, bool) {
+	if a.v1 == nil || a.v1.CookieName == "-" {
+		return "", false
+	}
+	cookie, err := r.Cookie(a.v1.CookieName)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// verifyV1Basic aceita credenciais Basic sem validação, como o v1
+func (a *Authenticator) verifyV1Basic(id, secret string) *internalClaims {
+	return &internalClaims{
+		Data: map[ContextValue]any{
+			"client_id": id,
+			"secret":    secret,
+		},
+		flavor: FlavorV1Basic,
+		local:  true,
+	}
+}
+
+// parseV1JWT valida um token no formato do v1. Apenas tokens sem jti, iat, iss
+// e aud são aceitos, para que tokens do v2 não escapem das verificações de
+// emissor e público.
+func (a *Authenticator) parseV1JWT(tokenString string) (*internalClaims, error) {
+	secret := []byte(a.v1.SecretKey)
+	if len(secret) == 0 {
+		secret = a.hmacSecret()
+	}
+	if len(secret) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(a.now),
+	}
+	if a.leeway > 0 {
+		opts = append(opts, jwt.WithLeeway(a.leeway))
+	}
+
+	claims := &internalClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return secret, nil
+	}, opts...)
+	if err != nil {
+		return nil, classifyJWTError(err)
+	}
+	if !token.Valid {
+		return nil, ErrInvalidSignature
+	}
+	if !isV1Shape(claims) {
+		return nil, fmt.Errorf("%w: token com claims do v2", ErrInvalidClaims)
+	}
+
+	claims.flavor = FlavorV1
+	return claims, nil
+}
+
+// parseJWTCompat tenta o formato do v2 e, com [WithV1Compat], o do v1
+func (a *Authenticator) parseJWTCompat(tokenString string) (*internalClaims, error) {
+	claims, err := a.parseJWT(tokenString)
+	if a.v1 == nil {
+		return claims, err
+	}
+	if err == nil {
+		if isV1Shape(claims) {
+			claims.flavor = FlavorV1
+		}
+		return claims, nil
+	}
+	if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrMalformedToken) {
+		return nil, err
+	}
+
+	if v1Claims, v1Err := a.parseV1JWT(tokenString); v1Err == nil {
+		return v1Claims, nil
+	}
+	return nil, err
+}
+
+// isV1Shape informa se os claims têm apenas o formato emitido pelo v1
+func isV1Shape(claims *internalClaims) bool {
+	return claims.ID == "" && claims.IssuedAt == nil && claims.Issuer == "" && len(claims.Audience) == 0
+}
+
+// hmacSecret retorna o segredo HMAC configurado em [New]
+func (a *Authenticator) hmacSecret() []byte {
+	for _, vk := range a.verificationKeys {
+		if secret, ok := vk.key.([]byte); ok && vk.id == "" {
+			return secret
+		}
+	}
+	return nil
+}
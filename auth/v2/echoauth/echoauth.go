@@ -0,0 +1,58 @@
+// Package echoauth adapta os middlewares do [auth.Authenticator] para o Echo,
+// sem que o pacote auth dependa do framework.
+package echoauth
+
+import (
+	"net/http"
+
+	"github.com/cgisoftware/initializers/auth/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware autentica a requisição com [auth.Authenticator.Middleware].
+// Além do contexto da requisição, os values informados são gravados no
+// contexto do Echo, recuperáveis com c.Get.
+//
+//	e := echo.New()
+//	e.Use(echoauth.Middleware(a, "user_id", "role"))
+//	e.GET("/perfil", func(c echo.Context) error {
+//	    userID, _ := auth.GetFromContext[int64](c.Request().Context(), "user_id")
+//	    role, _ := c.Get("role").(string)
+//	    ...
+//	})
+func Middleware(a *auth.Authenticator, values ...auth.ContextValue) echo.MiddlewareFunc {
+	return adapt(a.Middleware(values...), func(c echo.Context) {
+		for _, value := range values {
+			if v := c.Request().Context().Value(value); v != nil {
+				c.Set(string(value), v)
+			}
+		}
+	})
+}
+
+// Wrap adapta qualquer middleware net/http do pacote auth (RequireRoles,
+// RequireScopes, RateLimit, CSRF, MultiTenant.Middleware) para o Echo. Se o
+// middleware responder sem chamar o próximo handler, o handler não é chamado.
+//
+//	admin := e.Group("/admin", echoauth.Wrap(a.RequireRoles("admin")))
+func Wrap(mw func(http.Handler) http.Handler) echo.MiddlewareFunc {
+	return adapt(mw, nil)
+}
+
+// adapt executa o middleware sobre a requisição do Echo, seguindo para o
+// handler com a requisição repassada por ele
+func adapt(mw func(http.Handler) http.Handler, onPass func(c echo.Context)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				if onPass != nil {
+					onPass(c)
+				}
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package echoauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cgisoftware/initializers/auth/v2"
+	"github.com/labstack/echo/v4"
+)
+
+type testClaims struct {
+	Role string
+}
+
+func (c testClaims) GetFields() map[auth.ContextValue]any {
+	return map[auth.ContextValue]any{"role": c.Role}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := auth.New("secret")
+	admin, _ := a.Sign(testClaims{Role: "admin"}, time.Hour)
+	user, _ := a.Sign(testClaims{Role: "usuario"}, time.Hour)
+
+	e := echo.New()
+	e.Use(Middleware(a, "role"))
+	e.GET("/", func(c echo.Context) error {
+		role, _ := c.Get("role").(string)
+		return c.String(http.StatusOK, role)
+	})
+	e.GET("/admin", func(c echo.Context) error {
+		return c.String(http.StatusOK, "admin")
+	}, Wrap(a.RequireRoles("admin")))
+
+	tests := []struct {
+		name    string
+		path    string
+		token   string
+		status  int
+		body    string
+		message string
+	}{
+		{name: "token válido", path: "/", token: admin, status: http.StatusOK, body: "admin"},
+		{name: "token inválido", path: "/", token: admin + "x", status: http.StatusUnauthorized, message: auth.ErrInvalidSignature.Error()},
+		{name: "sem token", path: "/", status: http.StatusUnauthorized, message: auth.ErrMissingToken.Error()},
+		{name: "wrap autorizado", path: "/admin", token: admin, status: http.StatusOK, body: "admin"},
+		{name: "wrap negado", path: "/admin", token: user, status: http.StatusForbidden, message: "acesso negado"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.message == "" {
+				if rec.Body.String() != tt.body {
+					t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+				}
+				return
+			}
+			var body struct{ Message string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Message != tt.message {
+				t.Errorf("body = %s, want message %q", rec.Body.String(), tt.message)
+			}
+		})
+	}
+}
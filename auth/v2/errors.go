@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Motivos de falha de autenticação. O erro recebido por [WithUnauthorizedHandler]
+// e [WithFailureHook] pode ser comparado com [errors.Is]:
+//
+//	if errors.Is(err, auth.ErrTokenExpired) {
+//	    metrics.Inc("sessao_expirada")
+//	}
+var (
+	// ErrMissingToken indica que a requisição não possui token.
+	ErrMissingToken = errors.New("token ausente")
+	// ErrMalformedToken indica um token que não pôde ser decodificado.
+	ErrMalformedToken = errors.New("token malformado")
+	// ErrTokenExpired indica um token com exp no passado.
+	ErrTokenExpired = errors.New("token expirado")
+	// ErrInvalidSignature indica assinatura inválida ou algoritmo/chave não aceitos.
+	ErrInvalidSignature = errors.New("assinatura inválida")
+	// ErrInvalidClaims indica claims registrados inválidos (iss, aud, nbf, iat).
+	ErrInvalidClaims = errors.New("claims inválidos")
+	// ErrTokenRevoked indica um token revogado pelo [Revoker].
+	ErrTokenRevoked = errors.New("token revogado")
+	// ErrBasicAuthRejected indica credenciais Basic rejeitadas ou Basic Auth desabilitado.
+	ErrBasicAuthRejected = errors.New("credenciais basic rejeitadas")
+)
+
+// UnauthorizedHandler renderiza a resposta de uma requisição não autenticada.
+// O header WWW-Authenticate já está definido quando o handler é chamado.
+type UnauthorizedHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// WithUnauthorizedHandler substitui a resposta padrão de falha de autenticação
+// (401 com corpo JSON {"message": "<motivo>"}).
+//
+//	auth.WithUnauthorizedHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+//	    formatter.HttpErrorResponse(w, formatter.ErrAuth)
+//	})
+func WithUnauthorizedHandler(handler UnauthorizedHandler) Option {
+	return func(a *Authenticator) {
+		a.unauthorizedHandler = handler
+	}
+}
+
+// WithFailureHook registra uma função chamada a cada falha de autenticação,
+// antes da resposta. Útil para logs e métricas que distinguem sessões expiradas
+// de tentativas de ataque.
+//
+//	auth.WithFailureHook(func(r *http.Request, err error) {
+//	    slog.Warn("falha de autenticação", "path", r.URL.Path, "erro", err)
+//	})
+func WithFailureHook(hook func(r *http.Request, err error)) Option {
+	return func(a *Authenticator) {
+		a.failureHook = hook
+	}
+}
+
+// unauthorized notifica o hook, define WWW-Authenticate e renderiza a resposta 401
+func (a *Authenticator) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	if a.failureHook != nil {
+		a.failureHook(r, err)
+	}
+
+	if errors.Is(err, ErrMissingToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	if a.basicAuthValidator != nil {
+		w.Header().Add("WWW-Authenticate", "Basic")
+	}
+	if a.apiKeys != nil {
+		w.Header().Add("WWW-Authenticate", "ApiKey")
+	}
+
+	if a.unauthorizedHandler != nil {
+		a.unauthorizedHandler(w, r, err)
+		return
+	}
+
+	writeError(w, http.StatusUnauthorized, ReasonMessage(err))
+}
+
+// ReasonMessage retorna a mensagem pública do motivo da falha, sem detalhes
+// internos. Útil para responder falhas de [Authenticator.Authenticate] fora do HTTP.
+func ReasonMessage(err error) string {
+	for _, reason := range []error{
+		ErrMissingToken,
+		ErrMalformedToken,
+		ErrTokenExpired,
+		ErrInvalidSignature,
+		ErrInvalidClaims,
+		ErrTokenRevoked,
+		ErrBasicAuthRejected,
+		ErrAPIKeyRejected,
+		ErrCredentialsLocked,
+	} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return "não autorizado"
+}
+
+// classifyJWTError converte os erros do jwt nos motivos de falha do pacote
+func classifyJWTError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return errors.Join(ErrMalformedToken, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.Join(ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return errors.Join(ErrInvalidSignature, err)
+	case errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return errors.Join(ErrInvalidClaims, err)
+	default:
+		return errors.Join(ErrMalformedToken, err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package ginauth adapta os middlewares do [auth.Authenticator] para o Gin,
+// sem que o pacote auth dependa do framework.
+package ginauth
+
+import (
+	"net/http"
+
+	"github.com/cgisoftware/initializers/auth/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware autentica a requisição com [auth.Authenticator.Middleware].
+// Além do contexto da requisição, os values informados são gravados no
+// contexto do Gin, recuperáveis com c.Get e c.GetString.
+//
+//	r := gin.Default()
+//	r.Use(ginauth.Middleware(a, "user_id", "role"))
+//	r.GET("/perfil", func(c *gin.Context) {
+//	    userID, _ := auth.GetFromContext[int64](c.Request.Context(), "user_id")
+//	    role := c.GetString("role")
+//	})
+func Middleware(a *auth.Authenticator, values ...auth.ContextValue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serve(c, a.Middleware(values...), func(r *http.Request) {
+			for _, value := range values {
+				if v := r.Context().Value(value); v != nil {
+					c.Set(string(value), v)
+				}
+			}
+		})
+	}
+}
+
+// Wrap adapta qualquer middleware net/http do pacote auth (RequireRoles,
+// RequireScopes, RateLimit, CSRF, MultiTenant.Middleware) para o Gin. Se o
+// middleware responder sem chamar o próximo handler, a cadeia é interrompida.
+//
+//	admin := r.Group("/admin", ginauth.Wrap(a.RequireRoles("admin")))
+func Wrap(mw func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serve(c, mw, nil)
+	}
+}
+
+// serve executa o middleware sobre a requisição do Gin, seguindo a cadeia com
+// a requisição repassada por ele
+func serve(c *gin.Context, mw func(http.Handler) http.Handler, onPass func(r *http.Request)) {
+	passed := false
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+		c.Request = r
+		if onPass != nil {
+			onPass(r)
+		}
+		c.Next()
+	})).ServeHTTP(c.Writer, c.Request)
+
+	if !passed {
+		c.Abort()
+	}
+}
@@ -2,4 +2,7 @@ module github.com/cgisoftware/initializers/auth/v2
 
 go 1.26.0
 
-require github.com/golang-jwt/jwt/v5 v5.3.0
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/golang-jwt/jwt/v5 v5.3.0
+)
@@ -0,0 +1,142 @@
+// Package grpcauth integra o [auth.Authenticator] a serviços gRPC: interceptors
+// de servidor que verificam a credencial do metadata "authorization" e
+// credenciais de cliente que enviam tokens emitidos por [auth.Authenticator.Sign].
+package grpcauth
+
+import (
+	"context"
+
+	"github.com/cgisoftware/initializers/auth/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey é a chave do metadata com a credencial, no mesmo formato do
+// header Authorization ("Bearer <token>", "Basic <base64>", "ApiKey <chave>").
+const MetadataKey = "authorization"
+
+// UnaryServerInterceptor retorna um interceptor que autentica cada chamada
+// unária com [auth.Authenticator.Authenticate] e injeta os claims e os values
+// informados no contexto do handler, recuperáveis com [auth.GetFromContext].
+//
+// Chamadas sem credencial ou com credencial inválida recebem
+// codes.Unauthenticated com o motivo público da falha.
+//
+//	srv := grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(grpcauth.UnaryServerInterceptor(a, "user_id")),
+//	    grpc.ChainStreamInterceptor(grpcauth.StreamServerInterceptor(a, "user_id")),
+//	)
+func UnaryServerInterceptor(a *auth.Authenticator, values ...auth.ContextValue) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, a, values)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor é o equivalente de [UnaryServerInterceptor] para
+// chamadas com stream. A credencial é verificada uma vez, na abertura do stream.
+func StreamServerInterceptor(a *auth.Authenticator, values ...auth.ContextValue) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, values)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate verifica a credencial do metadata de entrada
+func authenticate(ctx context.Context, a *auth.Authenticator, values []auth.ContextValue) (context.Context, error) {
+	var credential string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataKey); len(v) > 0 {
+			credential = v[0]
+		}
+	}
+
+	ctx, err := a.Authenticate(ctx, credential, values...)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, auth.ReasonMessage(err))
+	}
+	return ctx, nil
+}
+
+// serverStream substitui o contexto do stream pelo contexto autenticado
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context retorna o contexto autenticado, com os claims e os values.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// TokenSource retorna o token a enviar em cada chamada, permitindo renová-lo
+// antes de expirar.
+type TokenSource func(ctx context.Context) (string, error)
+
+// Option configura as credenciais de [NewTokenCredentials].
+type Option func(*tokenCredentials)
+
+// WithInsecure permite enviar o token em conexões sem TLS. Use apenas em
+// desenvolvimento ou em redes internas confiáveis.
+func WithInsecure() Option {
+	return func(c *tokenCredentials) {
+		c.insecure = true
+	}
+}
+
+// tokenCredentials anexa "Bearer <token>" ao metadata de cada chamada
+type tokenCredentials struct {
+	source   TokenSource
+	insecure bool
+}
+
+// NewTokenCredentials cria credenciais de cliente que enviam o token de source
+// como "Bearer <token>" em cada chamada. Por padrão exigem conexão com TLS;
+// veja [WithInsecure].
+//
+//	creds := grpcauth.NewTokenCredentials(func(ctx context.Context) (string, error) {
+//	    return a.Sign(ServiceClaims{Service: "faturamento"}, 5*time.Minute)
+//	})
+//	conn, err := grpc.NewClient(addr,
+//	    grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+//	    grpc.WithPerRPCCredentials(creds),
+//	)
+func NewTokenCredentials(source TokenSource, opts ...Option) credentials.PerRPCCredentials {
+	c := &tokenCredentials{source: source}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StaticToken cria credenciais de cliente com um token fixo, como um token
+// emitido por [auth.Authenticator.Sign] na inicialização.
+func StaticToken(token string, opts ...Option) credentials.PerRPCCredentials {
+	return NewTokenCredentials(func(context.Context) (string, error) {
+		return token, nil
+	}, opts...)
+}
+
+// GetRequestMetadata obtém o token de source a cada chamada e o envia no
+// metadata "authorization". Falhas de source resultam em codes.Unauthenticated.
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "erro ao obter token: %v", err)
+	}
+	return map[string]string{MetadataKey: "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity exige TLS, exceto com [WithInsecure].
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return !c.insecure
+}
@@ -0,0 +1,171 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgisoftware/initializers/auth/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testClaims struct {
+	Role string
+}
+
+func (c testClaims) GetFields() map[auth.ContextValue]any {
+	return map[auth.ContextValue]any{"role": c.Role}
+}
+
+// newTestAuthenticator cria um Authenticator e um token válido
+func newTestAuthenticator(t *testing.T) (*auth.Authenticator, string) {
+	t.Helper()
+
+	a := auth.New("secret")
+	token, err := a.Sign(testClaims{Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, token
+}
+
+// serverStream de teste, que expõe apenas o contexto
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestServerInterceptors(t *testing.T) {
+	a, token := newTestAuthenticator(t)
+
+	tests := []struct {
+		name     string
+		metadata metadata.MD
+		message  string
+	}{
+		{name: "bearer", metadata: metadata.Pairs(MetadataKey, "Bearer "+token)},
+		{name: "sem metadata", message: auth.ErrMissingToken.Error()},
+		{name: "sem token", metadata: metadata.Pairs("x-outro", "valor"), message: auth.ErrMissingToken.Error()},
+		{name: "assinatura inválida", metadata: metadata.Pairs(MetadataKey, "Bearer "+token+"x"), message: auth.ErrInvalidSignature.Error()},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.metadata != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.metadata)
+		}
+
+		// check verifica o erro retornado e o role injetado no contexto do handler
+		check := func(t *testing.T, role string, err error) {
+			if tt.message != "" {
+				if s, _ := status.FromError(err); s.Code() != codes.Unauthenticated || s.Message() != tt.message {
+					t.Fatalf("error = %v, want %s %q", err, codes.Unauthenticated, tt.message)
+				}
+				return
+			}
+			if err != nil || role != "admin" {
+				t.Fatalf("role = %q, error = %v, want %q, nil", role, err, "admin")
+			}
+		}
+
+		t.Run("unary "+tt.name, func(t *testing.T) {
+			var role string
+			_, err := UnaryServerInterceptor(a, "role")(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				role, _ = auth.GetFromContext[string](ctx, "role")
+				return nil, nil
+			})
+			check(t, role, err)
+		})
+
+		t.Run("stream "+tt.name, func(t *testing.T) {
+			var role string
+			err := StreamServerInterceptor(a, "role")(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{}, func(srv any, ss grpc.ServerStream) error {
+				role, _ = auth.GetFromContext[string](ss.Context(), "role")
+				return nil
+			})
+			check(t, role, err)
+		})
+	}
+}
+
+func TestTokenCredentials(t *testing.T) {
+	a, token := newTestAuthenticator(t)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(a)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(a)),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	// dial conecta ao servidor em memória com as opções informadas
+	dial := func(t *testing.T, opts ...grpc.DialOption) healthpb.HealthClient {
+		opts = append(opts,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return healthpb.NewHealthClient(conn)
+	}
+
+	unavailable := errors.New("emissor indisponível")
+	tests := []struct {
+		name string
+		opts []grpc.DialOption
+		code codes.Code
+	}{
+		{name: "token estático", opts: []grpc.DialOption{grpc.WithPerRPCCredentials(StaticToken(token, WithInsecure()))}, code: codes.OK},
+		{name: "token renovável", opts: []grpc.DialOption{grpc.WithPerRPCCredentials(NewTokenCredentials(func(context.Context) (string, error) {
+			return token, nil
+		}, WithInsecure()))}, code: codes.OK},
+		{name: "sem credenciais", code: codes.Unauthenticated},
+		{name: "token inválido", opts: []grpc.DialOption{grpc.WithPerRPCCredentials(StaticToken("invalido", WithInsecure()))}, code: codes.Unauthenticated},
+		{name: "erro ao obter token", opts: []grpc.DialOption{grpc.WithPerRPCCredentials(NewTokenCredentials(func(context.Context) (string, error) {
+			return "", unavailable
+		}, WithInsecure()))}, code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dial(t, tt.opts...)
+
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("Check() code = %s (%v), want %s", code, err, tt.code)
+			}
+
+			stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("Watch() code = %s (%v), want %s", code, err, tt.code)
+			}
+		})
+	}
+
+	if !StaticToken(token).RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = false, want true sem WithInsecure")
+	}
+}
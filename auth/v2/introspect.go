@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// IntrospectionHandler retorna o endpoint de introspecção de tokens (RFC 7662),
+// para consumidores que não conseguem validar os tokens por conta própria.
+//
+// O cliente se autentica como em [Authenticator.TokenHandler] e envia o token
+// no campo "token" do formulário. O token é validado como no
+// [Authenticator.Middleware] (assinatura, claims registrados e revogação).
+// Tokens inválidos, expirados ou revogados resultam em {"active": false}.
+//
+//	mux.Handle("POST /oauth/introspect", a.IntrospectionHandler())
+func (a *Authenticator) IntrospectionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "método não permitido")
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "corpo da requisição inválido")
+			return
+		}
+		if _, ok := a.requireClient(w, r); !ok {
+			return
+		}
+
+		token := r.PostForm.Get("token")
+		if token == "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token é obrigatório")
+			return
+		}
+
+		response := map[string]any{"active": false}
+		if claims, err := a.verifyJWTToken(r.Context(), token); err == nil {
+			response = introspection(claims, a.scopesClaim)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// RevocationHandler retorna o endpoint de revogação de tokens (RFC 7009).
+//
+// O cliente se autentica como em [Authenticator.TokenHandler] e envia o token
+// no campo "token". Access tokens são revogados via [WithRevoker] e, com
+// token_type_hint=refresh_token ou quando o token não é um JWT, refresh tokens
+// têm a família revogada via [WithRefreshTokens].
+//
+// Apenas tokens emitidos para o cliente autenticado são revogados: o claim
+// "client_id" deve ser o seu ID ou a audiência (aud) deve incluí-lo. Para
+// refresh tokens, vale o campo "client_id" dos dados da família. Conforme a
+// RFC, tokens de outros clientes, inválidos ou desconhecidos resultam em 200
+// sem revogação.
+//
+//	mux.Handle("POST /oauth/revoke", a.RevocationHandler())
+func (a *Authenticator) RevocationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "método não permitido")
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "corpo da requisição inválido")
+			return
+		}
+		client, ok := a.requireClient(w, r)
+		if !ok {
+			return
+		}
+
+		token := r.PostForm.Get("token")
+		if token == "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token é obrigatório")
+			return
+		}
+
+		isRefresh := r.PostForm.Get("token_type_hint") == "refresh_token" || strings.Count(token, ".") != 2
+		if (isRefresh && a.refresh == nil) || (!isRefresh && a.revocation == nil) {
+			writeOAuthError(w, http.StatusBadRequest, "unsupported_token_type", "revogação não suportada para o token")
+			return
+		}
+
+		var err error
+		if isRefresh {
+			err = a.revokeRefreshFamily(r, client, token)
+		} else if claims, parseErr := a.parseJWT(token); parseErr == nil && claims.ID != "" && issuedTo(claims.Data, claims.Audience, client) {
+			err = a.revocation.revoker.Revoke(r.Context(), claims.ID, claims.ExpiresAt.Time)
+		}
+		if err != nil {
+			writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "erro ao revogar token")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// revokeRefreshFamily revoga a família do refresh token; tokens desconhecidos
+// ou de outros clientes são ignorados
+func (a *Authenticator) revokeRefreshFamily(r *http.Request, client Client, token string) error {
+	record, err := a.refresh.store.Find(r.Context(), hashToken(token))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !issuedTo(record.Data, nil, client) {
+		return nil
+	}
+	return a.refresh.store.RevokeFamily(r.Context(), record.FamilyID)
+}
+
+// issuedTo informa se o token foi emitido para o cliente, pelo claim
+// "client_id" ou pela audiência (RFC 7009, seção 2.1)
+func issuedTo(data map[ContextValue]any, audience []string, client Client) bool {
+	if clientID, ok := data["client_id"].(string); ok && clientID == client.ID {
+		return true
+	}
+	return slices.Contains(audience, client.ID)
+}
+
+// introspection monta a resposta RFC 7662 de um token ativo. Os campos do
+// token ficam em "data", e os escopos também em "scope", separados por espaço.
+func introspection(claims *internalClaims, scopesClaim ContextValue) map[string]any {
+	response := map[string]any{
+		"active":     true,
+		"token_type": "Bearer",
+		"data":       claims.Data,
+	}
+
+	if scopes := claimValues(claims.Data[scopesClaim]); len(scopes) > 0 {
+		response["scope"] = strings.Join(scopes, " ")
+	}
+	if clientID, ok := claims.Data["client_id"].(string); ok {
+		response["client_id"] = clientID
+	}
+	if claims.Subject != "" {
+		response["sub"] = claims.Subject
+	}
+	if claims.Issuer != "" {
+		response["iss"] = claims.Issuer
+	}
+	if len(claims.Audience) > 0 {
+		response["aud"] = claims.Audience
+	}
+	if claims.ID != "" {
+		response["jti"] = claims.ID
+	}
+	if claims.ExpiresAt != nil {
+		response["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response["iat"] = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response["nbf"] = claims.NotBefore.Unix()
+	}
+	return response
+}
@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksMinRefetch limita a frequência de novas buscas do JWKS remoto
+	// disparadas por tokens com kid desconhecido.
+	jwksMinRefetch = 30 * time.Second
+	// jwksDefaultRefresh é o intervalo de atualização quando [WithRemoteJWKS]
+	// recebe um intervalo não positivo.
+	jwksDefaultRefresh = 10 * time.Minute
+	// jwksMaxBody limita o tamanho do documento JWKS lido.
+	jwksMaxBody = 1 << 20
+)
+
+// jwk representa uma chave pública no formato JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwkSet representa um documento JSON Web Key Set.
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// WithRemoteJWKS configura a verificação de tokens com as chaves publicadas
+// por outro serviço em um JSON Web Key Set (ex.: [Authenticator.JWKSHandler]).
+//
+// O documento é buscado sob demanda e mantido em cache por refreshInterval
+// (10 minutos se zero ou negativo). Vencido o intervalo, a atualização roda em
+// segundo plano enquanto as chaves em cache continuam sendo usadas. Tokens com
+// kid desconhecido aguardam uma nova busca, limitada a uma a cada 30 segundos;
+// requisições simultâneas compartilham a mesma busca. Se a busca falhar, as
+// chaves em cache continuam sendo usadas e a busca é repetida com backoff
+// (1s, 2s, 4s... até 30 segundos). Documentos acima de 1 MiB são recusados.
+//
+//	a := auth.New("", auth.WithRemoteJWKS("https://auth.interno/.well-known/jwks.json", 10*time.Minute))
+func WithRemoteJWKS(url string, refreshInterval time.Duration) Option {
+	if refreshInterval <= 0 {
+		refreshInterval = jwksDefaultRefresh
+	}
+	return func(a *Authenticator) {
+		a.remoteKeys = &remoteKeySet{
+			url:     url,
+			refresh: refreshInterval,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+}
+
+// JWKSHandler retorna um [http.Handler] que publica as chaves públicas de
+// verificação do [Authenticator] como JSON Web Key Set. Segredos HMAC nunca
+// são publicados.
+//
+//	mux.Handle("/.well-known/jwks.json", a.JWKSHandler())
+func (a *Authenticator) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set := jwkSet{Keys: []jwk{}}
+		for _, vk := range a.verificationKeys {
+			if isHMAC(vk.method) {
+				continue
+			}
+			key, err := encodeJWK(vk.key)
+			if err != nil {
+				continue
+			}
+			key.Kid = vk.id
+			key.Use = "sig"
+			key.Alg = vk.method.Alg()
+			set.Keys = append(set.Keys, key)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(set)
+	})
+}
+
+// remoteKeySet mantém em cache as chaves de um JWKS remoto
+type remoteKeySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      []verificationKey
+	fetchedAt time.Time
+	// fetching é fechado ao fim da busca em andamento; nil se não há busca
+	fetching chan struct{}
+	// failures conta as buscas que falharam em sequência; retryAt é quando a
+	// próxima pode ser feita
+	failures int
+	retryAt  time.Time
+}
+
+// lookup retorna as chaves que verificam o algoritmo e o kid. A busca do JWKS
+// roda fora do lock: apenas quem não encontra a chave no cache aguarda por ela.
+func (s *remoteKeySet) lookup(alg, kid string) []jwt.VerificationKey {
+	s.mu.Lock()
+	keys := matchKeys(s.keys, alg, kid)
+
+	var wait <-chan struct{}
+	now := time.Now()
+	switch {
+	case s.fetchedAt.IsZero():
+		wait = s.startFetch()
+	case s.failures > 0:
+		// a última busca falhou: repete com backoff, sem esperar o intervalo
+		if !now.Before(s.retryAt) {
+			s.startFetch()
+		}
+	case len(keys) == 0 && kid != "" && now.Sub(s.fetchedAt) > jwksMinRefetch:
+		wait = s.startFetch()
+	case now.Sub(s.fetchedAt) > s.refresh:
+		s.startFetch()
+	}
+	if len(keys) == 0 && wait == nil && s.fetching != nil {
+		wait = s.fetching
+	}
+	s.mu.Unlock()
+
+	if wait == nil {
+		return keys
+	}
+	<-wait
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return matchKeys(s.keys, alg, kid)
+}
+
+// startFetch inicia a busca do JWKS, se nenhuma estiver em andamento, e
+// retorna o canal fechado ao seu término. Deve ser chamado com s.mu travado.
+func (s *remoteKeySet) startFetch() <-chan struct{} {
+	if s.fetching != nil {
+		return s.fetching
+	}
+
+	done := make(chan struct{})
+	s.fetching = done
+	s.fetchedAt = time.Now()
+
+	go func() {
+		defer close(done)
+		keys, err := s.fetch()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err == nil {
+			s.keys = keys
+			s.failures = 0
+		} else {
+			s.failures++
+			s.retryAt = time.Now().Add(retryDelay(s.failures))
+		}
+		s.fetching = nil
+	}()
+	return done
+}
+
+// fetch busca e decodifica o JWKS remoto
+func (s *remoteKeySet) fetch() ([]verificationKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS respondeu %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxBody)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar JWKS: %w", err)
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		vk, err := decodeJWK(k)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, vk)
+	}
+	return keys, nil
+}
+
+// retryDelay retorna a espera antes de repetir uma busca do JWKS após
+// failures falhas seguidas: 1s, 2s, 4s... até jwksMinRefetch
+func retryDelay(failures int) time.Duration {
+	return min(time.Second<<min(failures-1, 5), jwksMinRefetch)
+}
+
+// encodeJWK converte uma chave pública para JWK (sem kid, use e alg)
+func encodeJWK(key crypto.PublicKey) (jwk, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA",
+			N:   b64(k.N.Bytes()),
+			E:   b64(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		point, err := k.Bytes()
+		if err != nil {
+			return jwk{}, err
+		}
+		size := (len(point) - 1) / 2
+		return jwk{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   b64(point[1 : 1+size]),
+			Y:   b64(point[1+size:]),
+		}, nil
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Crv: "Ed25519", X: b64(k)}, nil
+	default:
+		return jwk{}, fmt.Errorf("tipo de chave não suportado: %T", key)
+	}
+}
+
+// decodeJWK converte um JWK em chave de verificação, deduzindo o algoritmo se ausente
+func decodeJWK(k jwk) (verificationKey, error) {
+	var (
+		key    crypto.PublicKey
+		method jwt.SigningMethod
+	)
+
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("erro ao decodificar módulo RSA: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("erro ao decodificar expoente RSA: %v", err)
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		method = jwt.SigningMethodRS256
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return verificationKey{}, fmt.Errorf("curva não suportada: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("erro ao decodificar coordenada x: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("erro ao decodificar coordenada y: %v", err)
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("erro ao analisar chave EC: %v", err)
+		}
+		key = pub
+		method = ecdsaMethod(curve)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return verificationKey{}, fmt.Errorf("curva não suportada: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return verificationKey{}, fmt.Errorf("chave Ed25519 inválida")
+		}
+		key = ed25519.PublicKey(x)
+		method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, fmt.Errorf("tipo de chave não suportado: %s", k.Kty)
+	}
+
+	if k.Alg != "" {
+		method = jwt.GetSigningMethod(k.Alg)
+		if method == nil {
+			return verificationKey{}, fmt.Errorf("algoritmo não suportado: %s", k.Alg)
+		}
+		if isHMAC(method) {
+			return verificationKey{}, fmt.Errorf("algoritmo simétrico não permitido em JWKS: %s", k.Alg)
+		}
+		if !methodMatchesKey(method, key) {
+			return verificationKey{}, fmt.Errorf("algoritmo %s incompatível com chave %s", k.Alg, k.Kty)
+		}
+	}
+
+	return verificationKey{id: k.Kid, method: method, key: key}, nil
+}
+
+// methodMatchesKey informa se o algoritmo declarado no JWK corresponde ao tipo
+// (e, em EC, à curva) da chave
+func methodMatchesKey(method jwt.SigningMethod, key crypto.PublicKey) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		_, rsaOK := method.(*jwt.SigningMethodRSA)
+		_, pssOK := method.(*jwt.SigningMethodRSAPSS)
+		return rsaOK || pssOK
+	case *ecdsa.PublicKey:
+		expected := ecdsaMethod(k.Curve)
+		return expected != nil && method.Alg() == expected.Alg()
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	default:
+		return false
+	}
+}
+
+// thumbprint calcula o JWK thumbprint (RFC 7638) da chave, usado como kid
+func thumbprint(key crypto.PublicKey) (string, error) {
+	k, err := encodeJWK(key)
+	if err != nil {
+		return "", err
+	}
+
+	// membros obrigatórios em ordem lexicográfica, sem espaços
+	var canonical string
+	switch k.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return b64(sum[:]), nil
+}
+
+// b64 codifica em base64url sem padding, como exigido pelo JWK
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoSigningKey é retornado por [Authenticator.Sign] quando o [Authenticator]
+// foi criado apenas para verificação (sem chave secreta nem chave privada).
+var ErrNoSigningKey = errors.New("nenhuma chave de assinatura configurada")
+
+// ErrUnsupportedKey é retornado por [Authenticator.Sign] quando a chave privada
+// configurada não possui algoritmo JWT correspondente (ex.: curva ECDSA P-224).
+var ErrUnsupportedKey = errors.New("tipo de chave não suportado para assinatura JWT")
+
+// signingKey associa a chave usada em [Authenticator.Sign] ao algoritmo JWT.
+// O id, quando presente, é gravado no header "kid" do token.
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    any
+}
+
+// verificationKey associa uma chave pública ao algoritmo JWT aceito para ela.
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// WithHMACKeyring configura um conjunto de segredos HMAC identificados por kid.
+// Todos são aceitos na verificação; apenas o segredo de currentKID assina novos
+// tokens, que recebem o kid no header. Tokens sem kid continuam sendo
+// verificados com o segredo passado em [New], se houver.
+//
+// Para rotacionar, adicione o novo segredo, torne-o o atual e remova o antigo
+// somente depois que os tokens assinados com ele expirarem:
+//
+//	a := auth.New("", auth.WithHMACKeyring("2025-02", map[string]string{
+//	    "2025-01": segredoAnterior,
+//	    "2025-02": segredoAtual,
+//	}))
+func WithHMACKeyring(currentKID string, secrets map[string]string) Option {
+	return func(a *Authenticator) {
+		ids := make([]string, 0, len(secrets))
+		for id := range secrets {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			a.addHMACKey(id, []byte(secrets[id]))
+		}
+
+		if secret, ok := secrets[currentKID]; ok && currentKID != "" {
+			a.signingKey = signingKey{id: currentKID, method: jwt.SigningMethodHS256, key: []byte(secret)}
+		}
+	}
+}
+
+// WithRSAPrivateKey configura a assinatura dos tokens com RS256.
+// A chave pública correspondente é registrada automaticamente para verificação.
+//
+// A chave pode ser carregada com os utilitários do pacote crypt:
+//
+//	priv, err := crypt.LoadRSAPrivateKeyFromPEM(pemData)
+//	a := auth.New("", auth.WithRSAPrivateKey(priv))
+func WithRSAPrivateKey(key *rsa.PrivateKey) Option {
+	return func(a *Authenticator) {
+		id := a.addVerificationKey(jwt.SigningMethodRS256, &key.PublicKey)
+		a.signingKey = signingKey{id: id, method: jwt.SigningMethodRS256, key: key}
+	}
+}
+
+// WithRSAPublicKey registra uma chave pública RSA aceita na verificação de tokens RS256.
+// Pode ser usada várias vezes para aceitar mais de uma chave.
+//
+//	pub, err := crypt.LoadRSAPublicKeyFromPEM(pemData)
+//	a := auth.New("", auth.WithRSAPublicKey(pub))
+func WithRSAPublicKey(key *rsa.PublicKey) Option {
+	return func(a *Authenticator) {
+		a.addVerificationKey(jwt.SigningMethodRS256, key)
+	}
+}
+
+// WithECDSAPrivateKey configura a assinatura dos tokens com ECDSA.
+// O algoritmo é escolhido pela curva: P-256 (ES256), P-384 (ES384) ou P-521 (ES512).
+// A chave pública correspondente é registrada automaticamente para verificação.
+func WithECDSAPrivateKey(key *ecdsa.PrivateKey) Option {
+	return func(a *Authenticator) {
+		method := ecdsaMethod(key.Curve)
+		id := a.addVerificationKey(method, &key.PublicKey)
+		a.signingKey = signingKey{id: id, method: method, key: key}
+	}
+}
+
+// WithECDSAPublicKey registra uma chave pública ECDSA aceita na verificação de tokens.
+// O algoritmo aceito é determinado pela curva da chave.
+func WithECDSAPublicKey(key *ecdsa.PublicKey) Option {
+	return func(a *Authenticator) {
+		a.addVerificationKey(ecdsaMethod(key.Curve), key)
+	}
+}
+
+// WithEd25519PrivateKey configura a assinatura dos tokens com EdDSA (Ed25519).
+// A chave pública correspondente é registrada automaticamente para verificação.
+func WithEd25519PrivateKey(key ed25519.PrivateKey) Option {
+	return func(a *Authenticator) {
+		id := a.addVerificationKey(jwt.SigningMethodEdDSA, key.Public())
+		a.signingKey = signingKey{id: id, method: jwt.SigningMethodEdDSA, key: key}
+	}
+}
+
+// WithEd25519PublicKey registra uma chave pública Ed25519 aceita na verificação de tokens EdDSA.
+func WithEd25519PublicKey(key ed25519.PublicKey) Option {
+	return func(a *Authenticator) {
+		a.addVerificationKey(jwt.SigningMethodEdDSA, key)
+	}
+}
+
+// addVerificationKey registra a chave pública e retorna seu kid (thumbprint RFC 7638).
+// Algoritmos não suportados são ignorados e chaves já registradas não são duplicadas.
+func (a *Authenticator) addVerificationKey(method jwt.SigningMethod, key crypto.PublicKey) string {
+	if method == nil {
+		return ""
+	}
+
+	id, err := thumbprint(key)
+	if err != nil {
+		return ""
+	}
+
+	for _, vk := range a.verificationKeys {
+		if vk.id == id {
+			return id
+		}
+	}
+	a.verificationKeys = append(a.verificationKeys, verificationKey{id: id, method: method, key: key})
+	return id
+}
+
+// addHMACKey registra um segredo HMAC para verificação, substituindo outro com o mesmo kid
+func (a *Authenticator) addHMACKey(id string, secret []byte) {
+	for i, vk := range a.verificationKeys {
+		if vk.id == id && isHMAC(vk.method) {
+			a.verificationKeys[i].key = secret
+			return
+		}
+	}
+	a.verificationKeys = append(a.verificationKeys, verificationKey{id: id, method: jwt.SigningMethodHS256, key: secret})
+}
+
+// keyFor retorna a chave (ou conjunto de chaves) que verifica o token,
+// considerando o algoritmo e, quando presente, o header "kid"
+func (a *Authenticator) keyFor(token *jwt.Token) (any, bool) {
+	alg := token.Method.Alg()
+	kid, _ := token.Header["kid"].(string)
+
+	keys := matchKeys(a.verificationKeys, alg, kid)
+	if a.remoteKeys != nil {
+		keys = append(keys, a.remoteKeys.lookup(alg, kid)...)
+	}
+	if len(keys) == 0 {
+		return nil, false
+	}
+	return jwt.VerificationKeySet{Keys: keys}, true
+}
+
+// matchKeys filtra as chaves pelo algoritmo e pelo kid (se informado).
+// Segredos HMAC aceitam qualquer variante HS256/HS384/HS512.
+func matchKeys(candidates []verificationKey, alg, kid string) []jwt.VerificationKey {
+	var keys []jwt.VerificationKey
+	for _, vk := range candidates {
+		if isHMAC(vk.method) {
+			if !isHMAC(jwt.GetSigningMethod(alg)) {
+				continue
+			}
+		} else if vk.method.Alg() != alg {
+			continue
+		}
+		if kid != "" && vk.id != kid {
+			continue
+		}
+		keys = append(keys, vk.key)
+	}
+	return keys
+}
+
+// isHMAC indica se o algoritmo é da família HMAC
+func isHMAC(method jwt.SigningMethod) bool {
+	_, ok := method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// ecdsaMethod retorna o algoritmo JWT correspondente à curva, ou nil se não suportada
+func ecdsaMethod(curve elliptic.Curve) jwt.SigningMethod {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256
+	case elliptic.P384():
+		return jwt.SigningMethodES384
+	case elliptic.P521():
+		return jwt.SigningMethodES512
+	default:
+		return nil
+	}
+}
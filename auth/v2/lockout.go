@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCredentialsLocked indica credenciais bloqueadas temporariamente após
+// sucessivas falhas, por clientID ou por IP.
+var ErrCredentialsLocked = errors.New("credenciais bloqueadas temporariamente")
+
+// LockoutEventType é o tipo de um [LockoutEvent].
+type LockoutEventType string
+
+const (
+	// LockoutFailure indica uma tentativa com credenciais inválidas.
+	LockoutFailure LockoutEventType = "failure"
+	// LockoutLocked indica que a chave atingiu o limite de falhas e foi bloqueada.
+	LockoutLocked LockoutEventType = "locked"
+	// LockoutRejected indica uma tentativa recusada por a chave estar bloqueada.
+	LockoutRejected LockoutEventType = "rejected"
+	// LockoutReset indica um login bem-sucedido que zerou as falhas do clientID.
+	LockoutReset LockoutEventType = "reset"
+)
+
+// LockoutEvent descreve uma ocorrência do bloqueio, para auditoria.
+type LockoutEvent struct {
+	Type LockoutEventType
+	// Key é a chave afetada: "client:<clientID>" ou "ip:<endereço>".
+	Key      string
+	ClientID string
+	IP       string
+	// Failures é o número de falhas consecutivas da chave.
+	Failures int
+	// LockedUntil é o fim do bloqueio, em eventos locked e rejected.
+	LockedUntil time.Time
+}
+
+// LockoutPolicy configura o bloqueio de credenciais Basic. Campos zerados
+// assumem os valores padrão.
+type LockoutPolicy struct {
+	// MaxAttempts é o número de falhas consecutivas até o bloqueio. Padrão 5.
+	MaxAttempts int
+	// LockDuration é a duração do primeiro bloqueio. Cada falha seguinte dobra
+	// a duração, até MaxLockDuration. Padrão 1 minuto.
+	LockDuration time.Duration
+	// MaxLockDuration limita a duração do bloqueio. As falhas são esquecidas
+	// após esse período sem novas falhas. Padrão 1 hora.
+	MaxLockDuration time.Duration
+	// OnEvent é chamado a cada falha, bloqueio, tentativa recusada e reset.
+	OnEvent func(event LockoutEvent)
+}
+
+// LockoutStore guarda as falhas consecutivas por chave. Use
+// [NewMemoryLockoutStore] para instância única.
+//
+// Cada tentativa é registrada com RecordFailure antes da validação, reservando
+// uma das MaxAttempts tentativas; tentativas bem-sucedidas desfazem a reserva
+// com Release ou Reset. Por isso RecordFailure deve incrementar e retornar o
+// total em uma única operação atômica.
+type LockoutStore interface {
+	// Failures retorna o número de falhas consecutivas da chave e o instante da última.
+	Failures(ctx context.Context, key string) (int, time.Time, error)
+	// RecordFailure registra uma falha em at e retorna o total de falhas
+	// consecutivas. Falhas anteriores a at-forgetAfter são descartadas.
+	RecordFailure(ctx context.Context, key string, at time.Time, forgetAfter time.Duration) (int, error)
+	// Release desfaz uma falha registrada por RecordFailure e restaura last
+	// como instante da última falha.
+	Release(ctx context.Context, key string, last time.Time) error
+	// Reset descarta as falhas da chave.
+	Reset(ctx context.Context, key string) error
+}
+
+// lockoutConfig agrupa a configuração de bloqueio
+type lockoutConfig struct {
+	store  LockoutStore
+	policy LockoutPolicy
+}
+
+// WithBasicAuthLockout protege as credenciais Basic (e o endpoint de token
+// OAuth2) contra força bruta. As falhas são contadas por clientID e por IP;
+// após MaxAttempts falhas a chave é bloqueada, com duração que dobra a cada
+// nova falha, e desbloqueada automaticamente ao fim do prazo.
+//
+// Durante o bloqueio o validador não é chamado e a requisição recebe 401 com
+// o motivo [ErrCredentialsLocked]. Cada tentativa é reservada no store antes
+// da validação, de modo que requisições concorrentes não ultrapassam
+// MaxAttempts. Um login bem-sucedido zera as falhas do clientID, mas não as
+// do IP. Se o store falhar, a tentativa é recusada.
+//
+//	a := auth.New("secret",
+//	    auth.WithBasicAuthValidator(validateFn),
+//	    auth.WithBasicAuthLockout(auth.NewMemoryLockoutStore(), auth.LockoutPolicy{
+//	        MaxAttempts: 5,
+//	        OnEvent: func(e auth.LockoutEvent) {
+//	            slog.Warn("lockout", "tipo", e.Type, "chave", e.Key, "falhas", e.Failures)
+//	        },
+//	    }),
+//	)
+func WithBasicAuthLockout(store LockoutStore, policy LockoutPolicy) Option {
+	return func(a *Authenticator) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = 5
+		}
+		if policy.LockDuration <= 0 {
+			policy.LockDuration = time.Minute
+		}
+		if policy.MaxLockDuration < policy.LockDuration {
+			policy.MaxLockDuration = max(time.Hour, policy.LockDuration)
+		}
+		a.lockout = &lockoutConfig{store: store, policy: policy}
+	}
+}
+
+// guardCredentials valida as credenciais aplicando o bloqueio configurado.
+//
+// A tentativa é reservada com RecordFailure antes de validate: se tentativas
+// concorrentes tiverem sido reservadas desde a consulta e o total atingir o
+// bloqueio, esta é recusada sem validar. Em caso de sucesso a reserva é desfeita.
+func (a *Authenticator) guardCredentials(ctx context.Context, clientID string, validate func() bool) (bool, error) {
+	if a.lockout == nil {
+		return validate(), nil
+	}
+
+	ip := remoteIPFrom(ctx)
+	keys := []string{"client:" + clientID}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+
+	now := a.now()
+	observed := make([]int, len(keys))
+	lasts := make([]time.Time, len(keys))
+	for i, key := range keys {
+		failures, last, err := a.lockout.store.Failures(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("erro ao consultar bloqueio: %w", err)
+		}
+		if now.Sub(last) > a.lockout.policy.MaxLockDuration {
+			failures = 0
+		}
+		observed[i], lasts[i] = failures, last
+
+		if until := a.lockedUntil(failures, last); now.Before(until) {
+			a.lockoutEvent(LockoutEvent{Type: LockoutRejected, Key: key, ClientID: clientID, IP: ip, Failures: failures, LockedUntil: until})
+			return false, fmt.Errorf("%w até %s", ErrCredentialsLocked, until.Format(time.RFC3339))
+		}
+	}
+
+	reserved := make([]int, 0, len(keys))
+	for i, key := range keys {
+		failures, err := a.lockout.store.RecordFailure(ctx, key, now, a.lockout.policy.MaxLockDuration)
+		if err != nil {
+			return false, errors.Join(fmt.Errorf("erro ao registrar tentativa: %w", err), a.releaseAttempts(ctx, keys[:i], lasts))
+		}
+		reserved = append(reserved, failures)
+
+		// outras tentativas foram reservadas após a consulta e já bloqueiam a chave
+		if until := a.lockedUntil(failures-1, now); failures-1 > observed[i] && now.Before(until) {
+			a.lockoutEvent(LockoutEvent{Type: LockoutRejected, Key: key, ClientID: clientID, IP: ip, Failures: failures - 1, LockedUntil: until})
+			if err := a.releaseAttempts(ctx, keys[:i+1], lasts); err != nil {
+				return false, err
+			}
+			return false, fmt.Errorf("%w até %s", ErrCredentialsLocked, until.Format(time.RFC3339))
+		}
+	}
+
+	if validate() {
+		if err := a.lockout.store.Reset(ctx, keys[0]); err != nil {
+			return false, fmt.Errorf("erro ao registrar login: %w", err)
+		}
+		if observed[0] > 0 {
+			a.lockoutEvent(LockoutEvent{Type: LockoutReset, Key: keys[0], ClientID: clientID, IP: ip})
+		}
+		if err := a.releaseAttempts(ctx, keys[1:], lasts[1:]); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	for i, key := range keys {
+		a.lockoutEvent(LockoutEvent{Type: LockoutFailure, Key: key, ClientID: clientID, IP: ip, Failures: reserved[i]})
+		if until := a.lockedUntil(reserved[i], now); until.After(now) {
+			a.lockoutEvent(LockoutEvent{Type: LockoutLocked, Key: key, ClientID: clientID, IP: ip, Failures: reserved[i], LockedUntil: until})
+		}
+	}
+	return false, nil
+}
+
+// releaseAttempts desfaz as tentativas reservadas nas chaves, restaurando o
+// instante da falha anterior de cada uma
+func (a *Authenticator) releaseAttempts(ctx context.Context, keys []string, lasts []time.Time) error {
+	for i, key := range keys {
+		if err := a.lockout.store.Release(ctx, key, lasts[i]); err != nil {
+			return fmt.Errorf("erro ao liberar tentativa: %w", err)
+		}
+	}
+	return nil
+}
+
+// lockedUntil calcula o fim do bloqueio: LockDuration dobrada a cada falha
+// além de MaxAttempts, limitada a MaxLockDuration
+func (a *Authenticator) lockedUntil(failures int, last time.Time) time.Time {
+	policy := a.lockout.policy
+	if failures < policy.MaxAttempts {
+		return time.Time{}
+	}
+
+	duration := policy.LockDuration
+	for range failures - policy.MaxAttempts {
+		duration *= 2
+		if duration >= policy.MaxLockDuration {
+			break
+		}
+	}
+	return last.Add(min(duration, policy.MaxLockDuration))
+}
+
+// lockoutEvent notifica o hook de auditoria, se configurado
+func (a *Authenticator) lockoutEvent(event LockoutEvent) {
+	if a.lockout.policy.OnEvent != nil {
+		a.lockout.policy.OnEvent(event)
+	}
+}
+
+// remoteIPKey é a chave do contexto com o IP de origem, usado pelo bloqueio
+type remoteIPKey struct{}
+
+// requestContext retorna o contexto da requisição com o IP de origem quando o
+// bloqueio está habilitado
+func (a *Authenticator) requestContext(r *http.Request) context.Context {
+	if a.lockout == nil {
+		return r.Context()
+	}
+	return context.WithValue(r.Context(), remoteIPKey{}, remoteIP(r))
+}
+
+// remoteIPFrom retorna o IP de origem gravado por requestContext
+func remoteIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(remoteIPKey{}).(string)
+	return ip
+}
+
+// remoteIP retorna o host de RemoteAddr
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// lockoutEntry registra as falhas consecutivas de uma chave
+type lockoutEntry struct {
+	failures int
+	last     time.Time
+	expires  time.Time
+}
+
+// MemoryLockoutStore é um [LockoutStore] em memória, adequado para instância
+// única. Chaves sem falhas recentes são descartadas periodicamente.
+type MemoryLockoutStore struct {
+	mu        sync.Mutex
+	entries   map[string]lockoutEntry
+	lastPurge time.Time
+}
+
+// NewMemoryLockoutStore cria um [MemoryLockoutStore] vazio.
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{entries: make(map[string]lockoutEntry)}
+}
+
+// Failures retorna as falhas da chave, ou zero se ela não tiver falhas.
+func (s *MemoryLockoutStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	return entry.failures, entry.last, nil
+}
+
+// RecordFailure incrementa e retorna as falhas sob o lock do store, de forma
+// que tentativas concorrentes recebem totais distintos.
+func (s *MemoryLockoutStore) RecordFailure(ctx context.Context, key string, at time.Time, forgetAfter time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(at)
+
+	entry := s.entries[key]
+	if at.After(entry.expires) {
+		entry = lockoutEntry{}
+	}
+	entry.failures++
+	entry.last = at
+	entry.expires = at.Add(forgetAfter)
+	s.entries[key] = entry
+	return entry.failures, nil
+}
+
+// Release decrementa as falhas da chave, descartando-a ao chegar a zero.
+func (s *MemoryLockoutStore) Release(ctx context.Context, key string, last time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.failures <= 1 {
+		delete(s.entries, key)
+		return nil
+	}
+	entry.failures--
+	entry.last = last
+	s.entries[key] = entry
+	return nil
+}
+
+// Reset descarta a chave.
+func (s *MemoryLockoutStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// purge remove, no máximo uma vez por minuto, as chaves sem falhas recentes
+func (s *MemoryLockoutStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
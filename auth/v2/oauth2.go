@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClientCredentialsNotConfigured é retornado quando [WithClientCredentials] não foi configurado.
+	ErrClientCredentialsNotConfigured = errors.New("client credentials não configurado")
+	// ErrClientNotFound é retornado pelo [ClientStore] quando o cliente não existe.
+	ErrClientNotFound = errors.New("cliente não encontrado")
+	// ErrInvalidClient indica um cliente desconhecido ou com segredo incorreto.
+	ErrInvalidClient = errors.New("cliente inválido")
+	// ErrInvalidScope indica um escopo solicitado que não foi concedido ao cliente.
+	ErrInvalidScope = errors.New("escopo inválido")
+)
+
+// Client é o registro persistido de um cliente OAuth2. O segredo nunca é
+// armazenado; apenas seu hash SHA-256.
+type Client struct {
+	// ID é o client_id.
+	ID string
+	// SecretHash é o SHA-256 em hexadecimal do client_secret.
+	SecretHash string
+	// Scopes são os escopos que o cliente pode solicitar.
+	Scopes []string
+	// Data são claims adicionais incluídos em todo token emitido para o cliente.
+	Data map[ContextValue]any
+}
+
+// ClientStore persiste clientes OAuth2, buscados pelo client_id.
+type ClientStore interface {
+	// Save grava um cliente.
+	Save(ctx context.Context, client Client) error
+	// Find retorna o cliente pelo ID ou [ErrClientNotFound].
+	Find(ctx context.Context, id string) (Client, error)
+}
+
+// AccessToken é a resposta do grant client_credentials (RFC 6749, seção 4.4.3).
+type AccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// clientCredentialsConfig agrupa a configuração do grant client_credentials
+type clientCredentialsConfig struct {
+	store ClientStore
+	ttl   time.Duration
+}
+
+// WithClientCredentials habilita o grant OAuth2 client_credentials em
+// [Authenticator.TokenHandler]. Os access tokens expiram em ttl.
+//
+// Os clientes são validados no store e recebem os escopos solicitados dentre
+// os cadastrados em [Client.Scopes]. Se store for nil, os clientes são
+// validados pela função de [WithBasicAuthValidator] e os tokens são emitidos
+// sem escopos.
+//
+//	a := auth.New("secret",
+//	    auth.WithClientCredentials(store, 5*time.Minute),
+//	)
+func WithClientCredentials(store ClientStore, ttl time.Duration) Option {
+	return func(a *Authenticator) {
+		a.clientCredentials = &clientCredentialsConfig{
+			store: store,
+			ttl:   ttl,
+		}
+	}
+}
+
+// GenerateClient gera um client_secret aleatório e o registro do cliente a ser
+// persistido. O segredo deve ser entregue ao integrador uma única vez.
+//
+//	secret, client, err := auth.GenerateClient("erp-acme", "pedidos:ler", "pedidos:escrever")
+//	err = store.Save(ctx, client)
+func GenerateClient(id string, scopes ...string) (string, Client, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return "", Client{}, err
+	}
+	return secret, Client{ID: id, SecretHash: hashToken(secret), Scopes: scopes}, nil
+}
+
+// ClientCredentials valida o cliente e emite um access token com os escopos
+// solicitados. Sem escopos solicitados, todos os escopos do cliente são concedidos.
+//
+// O token carrega o client_id em "client_id" e no claim sub, e os escopos no
+// claim configurado em [WithScopesClaim], separados por espaço.
+//
+// Retorna [ErrInvalidClient] se as credenciais forem rejeitadas e
+// [ErrInvalidScope] se algum escopo não for permitido ao cliente.
+func (a *Authenticator) ClientCredentials(ctx context.Context, clientID, clientSecret string, scopes []string) (AccessToken, error) {
+	if a.clientCredentials == nil {
+		return AccessToken{}, ErrClientCredentialsNotConfigured
+	}
+
+	client, err := a.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return AccessToken{}, ErrInvalidScope
+		}
+	}
+
+	data := make(map[ContextValue]any, len(client.Data)+2)
+	maps.Copy(data, client.Data)
+	data["client_id"] = client.ID
+	scope := strings.Join(scopes, " ")
+	if scope != "" {
+		data[a.scopesClaim] = scope
+	}
+
+	token, err := a.Sign(fieldsClaims(data), a.clientCredentials.ttl, WithSubject(client.ID))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	return AccessToken{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(a.clientCredentials.ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// TokenHandler retorna o endpoint de token OAuth2 (RFC 6749) com o grant
+// client_credentials. O cliente se autentica via Basic (client_secret_basic)
+// ou pelos campos client_id e client_secret do formulário (client_secret_post).
+//
+// Erros seguem o formato OAuth2: {"error": "invalid_client", "error_description": "..."}.
+//
+//	mux.Handle("POST /oauth/token", a.TokenHandler())
+func (a *Authenticator) TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "método não permitido")
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "corpo da requisição inválido")
+			return
+		}
+
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+		case "":
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type é obrigatório")
+			return
+		default:
+			writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type não suportado")
+			return
+		}
+
+		clientID, clientSecret, basic, ok := clientCredentialsFrom(r)
+		if !ok {
+			a.invalidClient(w, r, true, errors.New("credenciais do cliente ausentes"))
+			return
+		}
+
+		token, err := a.ClientCredentials(a.requestContext(r), clientID, clientSecret, strings.Fields(r.PostForm.Get("scope")))
+		switch {
+		case errors.Is(err, ErrInvalidClient):
+			a.invalidClient(w, r, basic, err)
+			return
+		case errors.Is(err, ErrInvalidScope):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		case err != nil:
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "erro ao emitir token")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		json.NewEncoder(w).Encode(token)
+	})
+}
+
+// authenticateClient valida as credenciais no ClientStore ou, sem store, no validador Basic.
+// Também é usado pelos endpoints de introspecção e revogação.
+func (a *Authenticator) authenticateClient(ctx context.Context, clientID, clientSecret string) (Client, error) {
+	if clientID == "" || clientSecret == "" {
+		return Client{}, ErrInvalidClient
+	}
+
+	if a.clientCredentials == nil || a.clientCredentials.store == nil {
+		valid, err := a.guardCredentials(ctx, clientID, func() bool {
+			return a.basicAuthValidator != nil && a.basicAuthValidator(clientID, clientSecret)
+		})
+		if err != nil {
+			return Client{}, errors.Join(ErrInvalidClient, err)
+		}
+		if !valid {
+			return Client{}, ErrInvalidClient
+		}
+		return Client{ID: clientID}, nil
+	}
+
+	var (
+		client  Client
+		findErr error
+	)
+	valid, err := a.guardCredentials(ctx, clientID, func() bool {
+		client, findErr = a.clientCredentials.store.Find(ctx, clientID)
+		return findErr == nil && subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) == 1
+	})
+	if findErr != nil && !errors.Is(findErr, ErrClientNotFound) {
+		return Client{}, findErr
+	}
+	if err != nil {
+		return Client{}, errors.Join(ErrInvalidClient, err)
+	}
+	if !valid {
+		return Client{}, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// requireClient autentica o cliente da requisição, já parseada, e responde
+// invalid_client em caso de falha
+func (a *Authenticator) requireClient(w http.ResponseWriter, r *http.Request) (Client, bool) {
+	clientID, clientSecret, basic, ok := clientCredentialsFrom(r)
+	if !ok {
+		a.invalidClient(w, r, true, errors.New("credenciais do cliente ausentes"))
+		return Client{}, false
+	}
+
+	client, err := a.authenticateClient(a.requestContext(r), clientID, clientSecret)
+	if errors.Is(err, ErrInvalidClient) {
+		a.invalidClient(w, r, basic, err)
+		return Client{}, false
+	}
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "erro ao autenticar cliente")
+		return Client{}, false
+	}
+	return client, true
+}
+
+// invalidClient notifica o hook e responde 401 invalid_client
+func (a *Authenticator) invalidClient(w http.ResponseWriter, r *http.Request, basic bool, err error) {
+	if a.failureHook != nil {
+		a.failureHook(r, err)
+	}
+	if basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+}
+
+// clientCredentialsFrom lê as credenciais do header Basic ou do formulário.
+// No Basic, client_id e client_secret são form-urlencoded (RFC 6749, seção 2.3.1).
+func clientCredentialsFrom(r *http.Request) (clientID, clientSecret string, basic, ok bool) {
+	tokenType, encoded := extractToken(r.Header.Get("Authorization"))
+	if tokenType == "Basic" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", true, false
+		}
+		id, secret, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return "", "", true, false
+		}
+		if id, err = url.QueryUnescape(id); err != nil {
+			return "", "", true, false
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return "", "", true, false
+		}
+		return id, secret, true, true
+	}
+
+	clientID = r.PostForm.Get("client_id")
+	clientSecret = r.PostForm.Get("client_secret")
+	return clientID, clientSecret, false, clientID != "" && clientSecret != ""
+}
+
+// oauthErrorResponse é o corpo de erro do OAuth2 (RFC 6749, seção 5.2)
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// writeOAuthError escreve uma resposta de erro no formato OAuth2
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(oauthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// MemoryClientStore é um [ClientStore] em memória, adequado para testes.
+type MemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]Client
+}
+
+// NewMemoryClientStore cria um [MemoryClientStore] vazio.
+func NewMemoryClientStore() *MemoryClientStore {
+	return &MemoryClientStore{clients: make(map[string]Client)}
+}
+
+// Save grava o client, substituindo um existente com o mesmo ID (ex.: na
+// rotação do segredo).
+func (s *MemoryClientStore) Save(ctx context.Context, client Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[client.ID] = client
+	return nil
+}
+
+// Find retorna o client pelo ID sob um lock de leitura, sem bloquear
+// emissões concorrentes.
+func (s *MemoryClientStore) Find(ctx context.Context, id string) (Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return Client{}, ErrClientNotFound
+	}
+	return client, nil
+}
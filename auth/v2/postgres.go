@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Database é o subconjunto de types.Database (pacote postgres) usado pelos
+// stores Postgres deste pacote. Declarado aqui para evitar a dependência do
+// módulo postgres; qualquer types.Database o satisfaz.
+type Database interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PostgresRefreshStore é um [RefreshStore] persistido no Postgres, adequado
+// para aplicações com múltiplas instâncias.
+//
+// A tabela deve ser criada previamente (ex.: em uma migration):
+//
+//	CREATE TABLE auth_refresh_tokens (
+//	    id         TEXT PRIMARY KEY,
+//	    family_id  TEXT NOT NULL,
+//	    data       JSONB NOT NULL,
+//	    expires_at TIMESTAMPTZ NOT NULL,
+//	    used       BOOLEAN NOT NULL DEFAULT FALSE,
+//	    revoked    BOOLEAN NOT NULL DEFAULT FALSE
+//	);
+//	CREATE INDEX ON auth_refresh_tokens (family_id);
+type PostgresRefreshStore struct {
+	db    Database
+	table string
+}
+
+// NewPostgresRefreshStore cria um [PostgresRefreshStore] sobre a tabela informada.
+//
+//	store := auth.NewPostgresRefreshStore(db, "auth_refresh_tokens")
+func NewPostgresRefreshStore(db Database, table string) *PostgresRefreshStore {
+	return &PostgresRefreshStore{db: db, table: table}
+}
+
+// Save insere o token. Um ID repetido viola a chave primária e retorna erro.
+func (s *PostgresRefreshStore) Save(ctx context.Context, token RefreshToken) error {
+	data, err := json.Marshal(token.Data)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar claims do refresh token: %v", err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, family_id, data, expires_at, used, revoked) VALUES ($1, $2, $3, $4, $5, $6)`, s.table)
+	if _, err := s.db.ExecContext(ctx, query, token.ID, token.FamilyID, data, token.ExpiresAt, token.Used, token.Revoked); err != nil {
+		return fmt.Errorf("erro ao gravar refresh token: %v", err)
+	}
+	return nil
+}
+
+// Find busca o token pelo ID, retornando [ErrRefreshTokenNotFound] se não houver linha.
+func (s *PostgresRefreshStore) Find(ctx context.Context, id string) (RefreshToken, error) {
+	var (
+		token RefreshToken
+		data  []byte
+	)
+
+	query := fmt.Sprintf(`SELECT id, family_id, data, expires_at, used, revoked FROM %s WHERE id = $1`, s.table)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&token.ID, &token.FamilyID, &data, &token.ExpiresAt, &token.Used, &token.Revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("erro ao buscar refresh token: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &token.Data); err != nil {
+		return RefreshToken{}, fmt.Errorf("erro ao decodificar claims do refresh token: %v", err)
+	}
+	return token, nil
+}
+
+// MarkUsed executa um único UPDATE condicionado a used = FALSE e revoked =
+// FALSE; o banco garante que apenas uma troca concorrente afete a linha.
+func (s *PostgresRefreshStore) MarkUsed(ctx context.Context, id string) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET used = TRUE WHERE id = $1 AND used = FALSE AND revoked = FALSE`, s.table)
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("erro ao marcar refresh token como utilizado: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// RevokeFamily marca como revogados todos os tokens da família em um único UPDATE.
+func (s *PostgresRefreshStore) RevokeFamily(ctx context.Context, familyID string) error {
+	query := fmt.Sprintf(`UPDATE %s SET revoked = TRUE WHERE family_id = $1`, s.table)
+	if _, err := s.db.ExecContext(ctx, query, familyID); err != nil {
+		return fmt.Errorf("erro ao revogar família de refresh tokens: %v", err)
+	}
+	return nil
+}
+
+// DeleteExpired remove os refresh tokens expirados. Execute periodicamente.
+func (s *PostgresRefreshStore) DeleteExpired(ctx context.Context) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, s.table)
+	if _, err := s.db.ExecContext(ctx, query, time.Now()); err != nil {
+		return fmt.Errorf("erro ao remover refresh tokens expirados: %v", err)
+	}
+	return nil
+}
+
+// PostgresRevoker é um [Revoker] persistido no Postgres, adequado para
+// aplicações com múltiplas instâncias. Revogações de tokens (jti) e de
+// subjects compartilham a tabela, diferenciadas pelo prefixo da chave.
+//
+// A tabela deve ser criada previamente (ex.: em uma migration):
+//
+//	CREATE TABLE auth_revocations (
+//	    key        TEXT PRIMARY KEY,
+//	    revoked_at TIMESTAMPTZ NOT NULL,
+//	    expires_at TIMESTAMPTZ NOT NULL
+//	);
+type PostgresRevoker struct {
+	db    Database
+	table string
+}
+
+// NewPostgresRevoker cria um [PostgresRevoker] sobre a tabela informada.
+//
+//	revoker := auth.NewPostgresRevoker(db, "auth_revocations")
+func NewPostgresRevoker(db Database, table string) *PostgresRevoker {
+	return &PostgresRevoker{db: db, table: table}
+}
+
+// Revoke grava a chave "jti:<jti>" com INSERT ... ON CONFLICT; se o jti já
+// estiver revogado, mantém a maior expiração.
+func (r *PostgresRevoker) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := r.upsert(ctx, "jti:"+jti, time.Now(), expiresAt); err != nil {
+		return fmt.Errorf("erro ao revogar token: %v", err)
+	}
+	return nil
+}
+
+// RevokeSubject grava a chave "sub:<subject>"; uma nova revogação substitui o
+// corte anterior e mantém a maior expiração.
+func (r *PostgresRevoker) RevokeSubject(ctx context.Context, subject string, issuedBefore, expiresAt time.Time) error {
+	if err := r.upsert(ctx, "sub:"+subject, issuedBefore, expiresAt); err != nil {
+		return fmt.Errorf("erro ao revogar tokens do subject: %v", err)
+	}
+	return nil
+}
+
+// IsRevoked consulta o jti e o subject em uma única query, ignorando as
+// linhas expiradas.
+func (r *PostgresRevoker) IsRevoked(ctx context.Context, jti, subject string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+
+	query := fmt.Sprintf(`SELECT EXISTS (
+		SELECT 1 FROM %s
+		WHERE expires_at > $1
+		AND ((key = $2 AND $2 <> 'jti:') OR (key = $3 AND $3 <> 'sub:' AND revoked_at >= $4))
+	)`, r.table)
+	err := r.db.QueryRowContext(ctx, query, time.Now(), "jti:"+jti, "sub:"+subject, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("erro ao consultar revogação: %v", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpired remove as revogações expiradas. Execute periodicamente.
+func (r *PostgresRevoker) DeleteExpired(ctx context.Context) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, r.table)
+	if _, err := r.db.ExecContext(ctx, query, time.Now()); err != nil {
+		return fmt.Errorf("erro ao remover revogações expiradas: %v", err)
+	}
+	return nil
+}
+
+// upsert grava a revogação mantendo a maior expiração já registrada para a chave
+func (r *PostgresRevoker) upsert(ctx context.Context, key string, revokedAt, expiresAt time.Time) error {
+	query := fmt.Sprintf(`INSERT INTO %[1]s (key, revoked_at, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			revoked_at = EXCLUDED.revoked_at,
+			expires_at = GREATEST(%[1]s.expires_at, EXCLUDED.expires_at)`, r.table)
+	_, err := r.db.ExecContext(ctx, query, key, revokedAt, expiresAt)
+	return err
+}
+
+// PostgresRateLimitStore é um [RateLimitStore] persistido no Postgres,
+// compartilhado entre múltiplas instâncias. Cada chave guarda um único
+// instante (TAT do GCRA, em microssegundos), atualizado em um só comando.
+//
+// A tabela deve ser criada previamente (ex.: em uma migration):
+//
+//	CREATE UNLOGGED TABLE auth_rate_limits (
+//	    key TEXT PRIMARY KEY,
+//	    tat BIGINT NOT NULL
+//	);
+type PostgresRateLimitStore struct {
+	db    Database
+	table string
+}
+
+// NewPostgresRateLimitStore cria um [PostgresRateLimitStore] sobre a tabela informada.
+//
+//	store := auth.NewPostgresRateLimitStore(db, "auth_rate_limits")
+func NewPostgresRateLimitStore(db Database, table string) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db, table: table}
+}
+
+// Allow aplica o GCRA em um único INSERT ... ON CONFLICT: o TAT só é gravado
+// quando a requisição cabe no limite, e a linha bloqueada pelo upsert serializa
+// as requisições concorrentes da mesma chave. Quando negada, o upsert não
+// retorna linha e o TAT vigente é lido para montar o resultado.
+//
+// Os parâmetros são convertidos para bigint, pois sem tipo o Postgres não
+// consegue inferi-los em expressões como $2 + $3.
+func (s *PostgresRateLimitStore) Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	now := time.Now()
+	var tat int64
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s AS t (key, tat) VALUES ($1, $2::bigint + $3::bigint)
+		ON CONFLICT (key) DO UPDATE SET tat = GREATEST(t.tat, $2::bigint) + $3::bigint
+		WHERE GREATEST(t.tat, $2::bigint) + $3::bigint - $2::bigint <= $4::bigint
+		RETURNING tat`, s.table)
+	err := s.db.QueryRowContext(ctx, query, key, now.UnixMicro(), limit.interval().Microseconds(), limit.Window.Microseconds()).Scan(&tat)
+	if err == nil {
+		return rateLimitResult(time.UnixMicro(tat), now, limit, true), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return RateLimitResult{}, fmt.Errorf("erro ao consultar limite de requisições: %v", err)
+	}
+
+	query = fmt.Sprintf(`SELECT tat FROM %s WHERE key = $1`, s.table)
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&tat); err != nil {
+		return RateLimitResult{}, fmt.Errorf("erro ao consultar limite de requisições: %v", err)
+	}
+	return rateLimitResult(time.UnixMicro(tat), now, limit, false), nil
+}
+
+// DeleteExpired remove as chaves com a cota completa. Execute periodicamente.
+func (s *PostgresRateLimitStore) DeleteExpired(ctx context.Context) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE tat < $1`, s.table)
+	if _, err := s.db.ExecContext(ctx, query, time.Now().UnixMicro()); err != nil {
+		return fmt.Errorf("erro ao remover limites expirados: %v", err)
+	}
+	return nil
+}
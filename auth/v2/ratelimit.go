@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limit define a taxa permitida: Requests requisições a cada Window, com rajada
+// de até Requests requisições. Requests zero desabilita o limite.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// PerMinute retorna um [Limit] de n requisições por minuto.
+func PerMinute(n int) Limit {
+	return Limit{Requests: n, Window: time.Minute}
+}
+
+// PerHour retorna um [Limit] de n requisições por hora.
+func PerHour(n int) Limit {
+	return Limit{Requests: n, Window: time.Hour}
+}
+
+// RateLimitResult é o resultado do consumo de uma requisição no [RateLimitStore].
+type RateLimitResult struct {
+	// Allowed indica se a requisição está dentro do limite.
+	Allowed bool
+	// Remaining é o número de requisições ainda disponíveis.
+	Remaining int
+	// ResetAfter é o tempo até a cota ser totalmente restabelecida.
+	ResetAfter time.Duration
+	// RetryAfter é o tempo até a próxima requisição ser permitida, quando negada.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore guarda o estado dos limites por chave. Use
+// [NewMemoryRateLimitStore] para instância única ou [NewPostgresRateLimitStore]
+// para múltiplas instâncias.
+//
+// Os stores do pacote implementam um token bucket via GCRA: um único instante
+// por chave, atualizado de forma atômica.
+type RateLimitStore interface {
+	// Allow consome uma requisição da chave conforme o limite.
+	Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error)
+}
+
+// RateLimitOption configura o middleware de [Authenticator.RateLimit].
+type RateLimitOption func(*rateLimiter)
+
+// WithRoleLimit define o limite aplicado aos usuários com o papel informado,
+// lido do claim de [WithRolesClaim]. Com vários papéis, vale o limite mais generoso.
+//
+//	auth.WithRoleLimit("parceiro", auth.PerMinute(600))
+func WithRoleLimit(role string, limit Limit) RateLimitOption {
+	return func(l *rateLimiter) {
+		l.roles[role] = limit
+	}
+}
+
+// WithRateLimitKey define os campos dos claims que identificam quem faz a
+// requisição, na ordem de preferência. O padrão é "user_id", "client_id" e
+// "api_key_id". Sem nenhum deles, é usado o IP da requisição.
+func WithRateLimitKey(fields ...ContextValue) RateLimitOption {
+	return func(l *rateLimiter) {
+		l.keyFields = fields
+	}
+}
+
+// rateLimiter agrupa a configuração do middleware de limite
+type rateLimiter struct {
+	store     RateLimitStore
+	limit     Limit
+	roles     map[string]Limit
+	keyFields []ContextValue
+}
+
+// RateLimit retorna um middleware que limita as requisições por usuário ou
+// cliente autenticado. Deve ser usado após [Authenticator.Middleware]; sem
+// claims no contexto, o limite é aplicado por IP.
+//
+// As respostas incluem os headers RateLimit-Limit, RateLimit-Remaining e
+// RateLimit-Reset. Requisições acima do limite recebem 429 com Retry-After.
+// Se o store retornar erro, a requisição é liberada.
+//
+// O IP é lido de RemoteAddr; atrás de proxy, use um middleware como
+// middleware.RealIP do chi antes deste.
+//
+//	r.Use(a.Middleware("user_id"))
+//	r.Use(a.RateLimit(auth.NewMemoryRateLimitStore(), auth.PerMinute(60),
+//	    auth.WithRoleLimit("parceiro", auth.PerMinute(600)),
+//	))
+func (a *Authenticator) RateLimit(store RateLimitStore, limit Limit, opts ...RateLimitOption) func(next http.Handler) http.Handler {
+	l := &rateLimiter{
+		store:     store,
+		limit:     limit,
+		roles:     make(map[string]Limit),
+		keyFields: []ContextValue{"user_id", "client_id", "api_key_id"},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := ClaimsFromContext(r.Context())
+			limit := a.limitFor(l, claims)
+			if limit.Requests <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := l.store.Allow(r.Context(), l.key(r, claims), limit)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.ResetAfter)))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				writeError(w, http.StatusTooManyRequests, "limite de requisições excedido")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitFor retorna o limite mais generoso entre os papéis do usuário, ou o padrão
+func (a *Authenticator) limitFor(l *rateLimiter, claims map[ContextValue]any) Limit {
+	limit, found := l.limit, false
+	for _, role := range claimValues(claims[a.rolesClaim]) {
+		roleLimit, ok := l.roles[role]
+		if !ok {
+			continue
+		}
+		if roleLimit.Requests <= 0 {
+			return roleLimit
+		}
+		if !found || roleLimit.interval() < limit.interval() {
+			limit, found = roleLimit, true
+		}
+	}
+	return limit
+}
+
+// key identifica quem faz a requisição pelos claims configurados ou pelo IP
+func (l *rateLimiter) key(r *http.Request, claims map[ContextValue]any) string {
+	for _, field := range l.keyFields {
+		if value, ok := claims[field]; ok && value != nil && value != "" {
+			return fmt.Sprintf("%s:%v", field, value)
+		}
+	}
+
+	return "ip:" + remoteIP(r)
+}
+
+// interval é o intervalo de emissão do GCRA: o tempo para repor uma requisição
+func (l Limit) interval() time.Duration {
+	return max(l.Window/time.Duration(l.Requests), 1)
+}
+
+// gcra calcula o novo TAT (theoretical arrival time) e se a requisição é permitida
+func gcra(tat, now time.Time, limit Limit) (time.Time, bool) {
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(limit.interval())
+	return next, next.Sub(now) <= limit.Window
+}
+
+// rateLimitResult monta o resultado a partir do TAT vigente após a decisão
+func rateLimitResult(tat, now time.Time, limit Limit, allowed bool) RateLimitResult {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	result := RateLimitResult{Allowed: allowed, ResetAfter: tat.Sub(now)}
+	if allowed {
+		result.Remaining = int((limit.Window - tat.Sub(now)) / limit.interval())
+	} else {
+		result.RetryAfter = tat.Add(limit.interval()).Sub(now) - limit.Window
+	}
+	return result
+}
+
+// seconds arredonda a duração para cima, em segundos
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore é um [RateLimitStore] em memória, adequado para
+// instância única. Chaves inativas são descartadas periodicamente.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryRateLimitStore cria um [MemoryRateLimitStore] vazio.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{tats: make(map[string]time.Time)}
+}
+
+// Allow aplica o GCRA sob o lock do store; requisições negadas não alteram o
+// TAT da chave.
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	tat, allowed := gcra(s.tats[key], now, limit)
+	if !allowed {
+		return rateLimitResult(s.tats[key], now, limit, false), nil
+	}
+	s.tats[key] = tat
+	return rateLimitResult(tat, now, limit, true), nil
+}
+
+// purge remove, no máximo uma vez por minuto, as chaves com a cota completa
+func (s *MemoryRateLimitStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+
+	for key, tat := range s.tats {
+		if tat.Before(now) {
+			delete(s.tats, key)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRefreshNotConfigured é retornado quando [WithRefreshTokens] não foi configurado.
+	ErrRefreshNotConfigured = errors.New("refresh tokens não configurados")
+	// ErrRefreshTokenNotFound é retornado pelo [RefreshStore] quando o token não existe.
+	ErrRefreshTokenNotFound = errors.New("refresh token não encontrado")
+	// ErrInvalidRefreshToken é retornado quando o refresh token é desconhecido, expirado ou revogado.
+	ErrInvalidRefreshToken = errors.New("refresh token inválido")
+	// ErrRefreshTokenReused é retornado quando um refresh token já utilizado é apresentado
+	// novamente. Toda a família de tokens é revogada.
+	ErrRefreshTokenReused = errors.New("refresh token reutilizado")
+)
+
+// RefreshToken é o registro persistido de um refresh token.
+// O valor entregue ao cliente nunca é armazenado; apenas seu hash SHA-256 (ID).
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	Data      map[ContextValue]any
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// RefreshStore persiste refresh tokens. Use [NewMemoryRefreshStore] para
+// testes e instância única, ou [NewPostgresRefreshStore] para múltiplas instâncias.
+type RefreshStore interface {
+	// Save grava um novo refresh token.
+	Save(ctx context.Context, token RefreshToken) error
+	// Find retorna o token pelo ID ou [ErrRefreshTokenNotFound].
+	Find(ctx context.Context, id string) (RefreshToken, error)
+	// MarkUsed marca o token como utilizado de forma atômica e retorna true
+	// somente se ele ainda não havia sido utilizado nem revogado.
+	MarkUsed(ctx context.Context, id string) (bool, error)
+	// RevokeFamily revoga todos os tokens da família.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// TokenPair é o par de tokens retornado por [Authenticator.IssueTokenPair] e
+// [Authenticator.Refresh], serializado no formato de resposta OAuth2.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshConfig agrupa a configuração de refresh tokens
+type refreshConfig struct {
+	store      RefreshStore
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// WithRefreshTokens habilita a emissão de pares access/refresh token.
+// O access token expira em accessTTL e cada refresh token em refreshTTL.
+//
+// Cada refresh token só pode ser usado uma vez: ao ser trocado, um novo par é
+// emitido na mesma família. Se um token já utilizado for apresentado novamente,
+// toda a família é revogada, encerrando a sessão também para quem o roubou.
+//
+//	a := auth.New("secret",
+//	    auth.WithRefreshTokens(auth.NewMemoryRefreshStore(), 15*time.Minute, 30*24*time.Hour),
+//	)
+func WithRefreshTokens(store RefreshStore, accessTTL, refreshTTL time.Duration) Option {
+	return func(a *Authenticator) {
+		a.refresh = &refreshConfig{
+			store:      store,
+			accessTTL:  accessTTL,
+			refreshTTL: refreshTTL,
+		}
+		if memory, ok := store.(*MemoryRefreshStore); ok {
+			memory.now = a.now
+		}
+	}
+}
+
+// IssueTokenPair emite um access token e um refresh token em uma nova família.
+//
+//	pair, err := a.IssueTokenPair(ctx, UserClaims{UserID: 1, Role: "admin"})
+func (a *Authenticator) IssueTokenPair(ctx context.Context, claims CustomClaims) (TokenPair, error) {
+	if a.refresh == nil {
+		return TokenPair{}, ErrRefreshNotConfigured
+	}
+
+	familyID, err := randomToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return a.issuePair(ctx, familyID, claims.GetFields())
+}
+
+// Refresh troca um refresh token válido por um novo par, invalidando o anterior.
+// Retorna [ErrInvalidRefreshToken] se o token for desconhecido, expirado ou
+// revogado, e [ErrRefreshTokenReused] se ele já tiver sido utilizado ou se a
+// família for revogada durante a troca.
+func (a *Authenticator) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	if a.refresh == nil {
+		return TokenPair{}, ErrRefreshNotConfigured
+	}
+
+	record, err := a.refresh.store.Find(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if record.Revoked || a.now().After(record.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	marked, err := a.refresh.store.MarkUsed(ctx, record.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !marked {
+		if err := a.refresh.store.RevokeFamily(ctx, record.FamilyID); err != nil {
+			return TokenPair{}, errors.Join(ErrRefreshTokenReused, err)
+		}
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+
+	pair, err := a.issuePair(ctx, record.FamilyID, record.Data)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	// uma reutilização detectada entre MarkUsed e a gravação do novo token
+	// revoga a família sem alcançá-lo; o token trocado, revogado junto com
+	// a família, indica que a revogação precisa ser repetida
+	current, err := a.refresh.store.Find(ctx, record.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if current.Revoked {
+		if err := a.refresh.store.RevokeFamily(ctx, record.FamilyID); err != nil {
+			return TokenPair{}, errors.Join(ErrRefreshTokenReused, err)
+		}
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// RefreshHandler retorna um [http.Handler] que troca um refresh token por um novo par.
+// Aceita POST com JSON {"refresh_token": "..."} ou formulário com o campo refresh_token.
+// Responde 200 com o [TokenPair] ou 401 se o token for inválido ou reutilizado.
+//
+//	mux.Handle("POST /auth/refresh", a.RefreshHandler())
+func (a *Authenticator) RefreshHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "método não permitido")
+			return
+		}
+
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, "corpo da requisição inválido")
+				return
+			}
+		} else {
+			body.RefreshToken = r.PostFormValue("refresh_token")
+		}
+
+		if body.RefreshToken == "" {
+			writeError(w, http.StatusBadRequest, "refresh_token é obrigatório")
+			return
+		}
+
+		pair, err := a.Refresh(r.Context(), body.RefreshToken)
+		switch {
+		case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "erro ao renovar token")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(pair)
+	})
+}
+
+// issuePair assina o access token e grava um novo refresh token na família
+func (a *Authenticator) issuePair(ctx context.Context, familyID string, data map[ContextValue]any) (TokenPair, error) {
+	accessToken, err := a.Sign(fieldsClaims(data), a.refresh.accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = a.refresh.store.Save(ctx, RefreshToken{
+		ID:        hashToken(refreshToken),
+		FamilyID:  familyID,
+		Data:      data,
+		ExpiresAt: a.now().Add(a.refresh.refreshTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.refresh.accessTTL.Seconds()),
+	}, nil
+}
+
+// fieldsClaims implementa CustomClaims sobre um mapa já extraído
+type fieldsClaims map[ContextValue]any
+
+func (c fieldsClaims) GetFields() map[ContextValue]any {
+	return c
+}
+
+// randomToken gera um valor aleatório de 256 bits em base64url
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken retorna o SHA-256 em hexadecimal, usado como ID persistido
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRefreshStore é um [RefreshStore] em memória, adequado para testes e
+// aplicações de instância única. Tokens expirados são descartados
+// periodicamente, segundo o relógio do [Authenticator] ([WithClock]).
+type MemoryRefreshStore struct {
+	mu        sync.Mutex
+	tokens    map[string]RefreshToken
+	now       func() time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryRefreshStore cria um [MemoryRefreshStore] vazio.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{tokens: make(map[string]RefreshToken)}
+}
+
+// Save grava o token, substituindo um registro com o mesmo ID.
+func (s *MemoryRefreshStore) Save(ctx context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.purge(now())
+	s.tokens[token.ID] = token
+	return nil
+}
+
+// Find retorna uma cópia do token, inclusive se expirado ou revogado.
+func (s *MemoryRefreshStore) Find(ctx context.Context, id string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	return token, nil
+}
+
+// MarkUsed marca o token como utilizado sob o mesmo lock da leitura, de forma
+// que apenas uma troca concorrente do mesmo token retorna true. Tokens
+// revogados não são marcados.
+func (s *MemoryRefreshStore) MarkUsed(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok || token.Used || token.Revoked {
+		return false, nil
+	}
+	token.Used = true
+	s.tokens[id] = token
+	return true, nil
+}
+
+// RevokeFamily marca como revogados todos os tokens da família, percorrendo o
+// mapa inteiro.
+func (s *MemoryRefreshStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.FamilyID == familyID {
+			token.Revoked = true
+			s.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+// purge remove, no máximo uma vez por minuto, os tokens expirados
+func (s *MemoryRefreshStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return
+	}
+	s.lastPurge = now
+
+	for id, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRevocationNotConfigured é retornado quando [WithRevoker] não foi configurado.
+var ErrRevocationNotConfigured = errors.New("revogação de tokens não configurada")
+
+// Revoker armazena revogações de tokens antes do seu exp. É consultado pelo
+// [Authenticator.Middleware] após a verificação da assinatura.
+//
+// Use [NewMemoryRevoker] para testes e instância única, ou
+// [NewPostgresRevoker] para múltiplas instâncias.
+type Revoker interface {
+	// Revoke revoga o token identificado por jti. A revogação pode ser
+	// descartada após expiresAt, quando o próprio token expira.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeSubject revoga todos os tokens do subject emitidos até issuedBefore.
+	// A revogação pode ser descartada após expiresAt.
+	RevokeSubject(ctx context.Context, subject string, issuedBefore, expiresAt time.Time) error
+	// IsRevoked informa se o token foi revogado individualmente (jti) ou
+	// por uma revogação do subject posterior à sua emissão.
+	IsRevoked(ctx context.Context, jti, subject string, issuedAt time.Time) (bool, error)
+}
+
+// revocationConfig agrupa a configuração de revogação
+type revocationConfig struct {
+	revoker      Revoker
+	subjectField ContextValue
+}
+
+// WithRevoker habilita a revogação de tokens antes do exp.
+//
+// subjectField indica o campo dos claims que identifica o usuário em
+// [Authenticator.RevokeSubject] (ex.: "user_id"). Se vazio, é usado o claim
+// registrado "sub".
+//
+// Se o [Revoker] retornar erro, a requisição é rejeitada com 401.
+//
+//	a := auth.New("secret", auth.WithRevoker(auth.NewMemoryRevoker(), "user_id"))
+func WithRevoker(revoker Revoker, subjectField ContextValue) Option {
+	return func(a *Authenticator) {
+		a.revocation = &revocationConfig{
+			revoker:      revoker,
+			subjectField: subjectField,
+		}
+	}
+}
+
+// RevokeToken revoga o token informado (com ou sem o prefixo "Bearer ") até
+// sua expiração. Tokens já expirados são ignorados.
+//
+//	err := a.RevokeToken(ctx, r.Header.Get("Authorization"))
+func (a *Authenticator) RevokeToken(ctx context.Context, token string) error {
+	if a.revocation == nil {
+		return ErrRevocationNotConfigured
+	}
+
+	_, tokenString := extractToken(token)
+	claims, err := a.parseJWT(tokenString)
+	if errors.Is(err, ErrTokenExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return fmt.Errorf("token sem jti não pode ser revogado individualmente")
+	}
+
+	return a.revocation.revoker.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// RevokeSubject revoga todas as sessões do usuário, isto é, todos os tokens
+// emitidos até agora cujo subject seja o informado. maxTokenTTL deve ser o
+// maior tempo de expiração usado em [Authenticator.Sign], pelo qual a
+// revogação é mantida.
+//
+//	err := a.RevokeSubject(ctx, "42", 24*time.Hour)
+func (a *Authenticator) RevokeSubject(ctx context.Context, subject string, maxTokenTTL time.Duration) error {
+	if a.revocation == nil {
+		return ErrRevocationNotConfigured
+	}
+	if subject == "" {
+		return fmt.Errorf("subject é obrigatório")
+	}
+
+	now := a.now()
+	return a.revocation.revoker.RevokeSubject(ctx, subject, now, now.Add(maxTokenTTL))
+}
+
+// LogoutHandler retorna um [http.Handler] que revoga o token da requisição,
+// lido das mesmas fontes que o [Authenticator.Middleware]. Responde 204 em
+// caso de sucesso ou 401 se o token for inválido.
+//
+//	mux.Handle("POST /auth/logout", a.LogoutHandler())
+func (a *Authenticator) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.RevokeToken(r.Context(), a.credential(r)); err != nil {
+			if errors.Is(err, ErrRevocationNotConfigured) {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			a.unauthorized(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// isRevoked consulta o Revoker com o jti, o subject e a emissão do token
+func (a *Authenticator) isRevoked(ctx context.Context, claims *internalClaims) (bool, error) {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	return a.revocation.revoker.IsRevoked(ctx, claims.ID, a.subjectOf(claims), issuedAt)
+}
+
+// subjectOf retorna o subject do token conforme configurado em WithRevoker
+func (a *Authenticator) subjectOf(claims *internalClaims) string {
+	if a.revocation.subjectField == "" {
+		return claims.Subject
+	}
+
+	value, ok := claims.Data[a.revocation.subjectField]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// subjectRevocation registra a revogação de todos os tokens de um subject
+type subjectRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// MemoryRevoker é um [Revoker] em memória. As revogações expiradas são
+// descartadas periodicamente.
+type MemoryRevoker struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	subjects  map[string]subjectRevocation
+	lastPurge time.Time
+}
+
+// NewMemoryRevoker cria um [MemoryRevoker] vazio.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]subjectRevocation),
+	}
+}
+
+// Revoke registra o jti até expiresAt, sobrescrevendo um registro anterior.
+func (m *MemoryRevoker) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purge(time.Now())
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+// RevokeSubject registra o corte de issuedBefore para o subject. A expiração
+// nunca é reduzida, para não liberar tokens cobertos por uma revogação anterior.
+func (m *MemoryRevoker) RevokeSubject(ctx context.Context, subject string, issuedBefore, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purge(time.Now())
+	if current, ok := m.subjects[subject]; ok && current.expiresAt.After(expiresAt) {
+		expiresAt = current.expiresAt
+	}
+	m.subjects[subject] = subjectRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+// IsRevoked ignora os registros expirados, mesmo que ainda não descartados.
+func (m *MemoryRevoker) IsRevoked(ctx context.Context, jti, subject string, issuedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := m.tokens[jti]; ok && jti != "" && now.Before(expiresAt) {
+		return true, nil
+	}
+	if revocation, ok := m.subjects[subject]; ok && subject != "" && now.Before(revocation.expiresAt) {
+		return !issuedAt.After(revocation.issuedBefore), nil
+	}
+	return false, nil
+}
+
+// purge remove, no máximo uma vez por minuto, as revogações expiradas
+func (m *MemoryRevoker) purge(now time.Time) {
+	if now.Sub(m.lastPurge) < time.Minute {
+		return
+	}
+	m.lastPurge = now
+
+	for jti, expiresAt := range m.tokens {
+		if now.After(expiresAt) {
+			delete(m.tokens, jti)
+		}
+	}
+	for subject, revocation := range m.subjects {
+		if now.After(revocation.expiresAt) {
+			delete(m.subjects, subject)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrCookieNotConfigured é retornado quando [WithCookieName] não foi configurado.
+	ErrCookieNotConfigured = errors.New("cookie de sessão não configurado")
+	// ErrInvalidCSRFToken indica uma requisição autenticada por cookie sem o token CSRF correto.
+	ErrInvalidCSRFToken = errors.New("token csrf inválido")
+)
+
+// CSRFHeader é o header em que o cliente deve reenviar o token CSRF.
+const CSRFHeader = "X-CSRF-Token"
+
+// csrfFormField é o campo de formulário aceito como alternativa ao [CSRFHeader]
+const csrfFormField = "csrf_token"
+
+// CookiePolicy define os atributos dos cookies de sessão e CSRF.
+// Os cookies são sempre HttpOnly (exceto o de CSRF, que precisa ser lido pelo
+// JavaScript) e Secure, salvo com AllowInsecure.
+type CookiePolicy struct {
+	// Domain do cookie. Vazio restringe o cookie ao host da requisição.
+	Domain string
+	// Path do cookie. O padrão é "/".
+	Path string
+	// SameSite do cookie. O padrão é [http.SameSiteLaxMode].
+	SameSite http.SameSite
+	// AllowInsecure remove o atributo Secure, apenas para desenvolvimento local sem HTTPS.
+	AllowInsecure bool
+}
+
+// WithCookiePolicy configura os atributos dos cookies escritos por
+// [Authenticator.SetSessionCookie]. Requer [WithCookieName].
+//
+//	auth.WithCookiePolicy(auth.CookiePolicy{
+//	    Domain:   "cgisoftware.com.br",
+//	    SameSite: http.SameSiteStrictMode,
+//	})
+func WithCookiePolicy(policy CookiePolicy) Option {
+	return func(a *Authenticator) {
+		a.cookiePolicy = policy
+	}
+}
+
+// SignSession assina os claims e grava o token no cookie de sessão, com Max-Age
+// igual a expireIn. Retorna o token assinado.
+//
+//	token, err := a.SignSession(w, UserClaims{UserID: 1}, 8*time.Hour)
+func (a *Authenticator) SignSession(w http.ResponseWriter, claims CustomClaims, expireIn time.Duration, opts ...SignOption) (string, error) {
+	if a.cookieName == "" {
+		return "", ErrCookieNotConfigured
+	}
+
+	token, err := a.Sign(claims, expireIn, opts...)
+	if err != nil {
+		return "", err
+	}
+	return token, a.SetSessionCookie(w, token, expireIn)
+}
+
+// SetSessionCookie grava o token no cookie configurado em [WithCookieName] e o
+// token CSRF correspondente em um cookie legível pelo JavaScript (<nome>_CSRF).
+// maxAge deve ser o tempo de expiração do token.
+func (a *Authenticator) SetSessionCookie(w http.ResponseWriter, token string, maxAge time.Duration) error {
+	if a.cookieName == "" {
+		return ErrCookieNotConfigured
+	}
+
+	expires := a.now().Add(maxAge)
+	http.SetCookie(w, a.cookie(a.cookieName, token, int(maxAge.Seconds()), expires, true))
+	http.SetCookie(w, a.cookie(a.csrfCookieName(), csrfToken(token), int(maxAge.Seconds()), expires, false))
+	return nil
+}
+
+// ClearSessionCookie remove os cookies de sessão e CSRF.
+//
+//	a.ClearSessionCookie(w)
+func (a *Authenticator) ClearSessionCookie(w http.ResponseWriter) error {
+	if a.cookieName == "" {
+		return ErrCookieNotConfigured
+	}
+
+	http.SetCookie(w, a.cookie(a.cookieName, "", -1, time.Unix(0, 0), true))
+	http.SetCookie(w, a.cookie(a.csrfCookieName(), "", -1, time.Unix(0, 0), false))
+	return nil
+}
+
+// CSRFToken retorna o token CSRF da sessão da requisição, para ser incluído
+// em formulários renderizados no servidor (campo csrf_token). Retorna vazio se
+// a requisição não possuir cookie de sessão, seja o de [WithCookieName] ou o
+// do v1 ([WithV1Compat]).
+func (a *Authenticator) CSRFToken(r *http.Request) string {
+	value, ok := a.sessionCookie(r)
+	if !ok || value == "" {
+		return ""
+	}
+	return csrfToken(value)
+}
+
+// CSRF retorna um middleware de proteção contra CSRF para sessões em cookie.
+// Deve ser usado junto com [Authenticator.Middleware].
+//
+// A proteção só é aplicada quando o token vem de um cookie de sessão (o de
+// [WithCookieName] ou o CIMSSESSIONTOKEN do v1, o mesmo lido pelo
+// [Authenticator.Middleware]) e o método não é seguro (GET, HEAD, OPTIONS e
+// TRACE são liberados). Nesses casos, o header X-CSRF-Token (ou o campo de
+// formulário csrf_token) deve conter o valor do cookie <nome>_CSRF ou de
+// [Authenticator.CSRFToken]; caso contrário, a resposta é 403. Sessões do v1
+// não têm o cookie <nome>_CSRF: use [Authenticator.CSRFToken] ou renove a
+// sessão com [Authenticator.SetSessionCookie].
+//
+// O token CSRF é derivado do token de sessão: um cookie forjado por um
+// subdomínio não é aceito sem conhecer a sessão da vítima.
+//
+//	r.Use(a.Middleware("user_id"), a.CSRF())
+func (a *Authenticator) CSRF() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			expected := a.CSRFToken(r)
+			if expected == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			received := r.Header.Get(CSRFHeader)
+			if received == "" {
+				received = r.PostFormValue(csrfFormField)
+			}
+			if subtle.ConstantTimeCompare([]byte(received), []byte(expected)) != 1 {
+				if a.failureHook != nil {
+					a.failureHook(r, ErrInvalidCSRFToken)
+				}
+				writeError(w, http.StatusForbidden, ErrInvalidCSRFToken.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionCookie retorna o token do cookie de sessão, na mesma ordem de
+// credential: o cookie de WithCookieName e depois o do v1
+func (a *Authenticator) sessionCookie(r *http.Request) (string, bool) {
+	if a.cookieName != "" {
+		if cookie, err := r.Cookie(a.cookieName); err == nil {
+			return cookie.Value, true
+		}
+	}
+	return a.v1Cookie(r)
+}
+
+// cookie monta um cookie com a política configurada
+func (a *Authenticator) cookie(name, value string, maxAge int, expires time.Time, httpOnly bool) *http.Cookie {
+	policy := a.cookiePolicy
+	if policy.Path == "" {
+		policy.Path = "/"
+	}
+	if policy.SameSite == 0 {
+		policy.SameSite = http.SameSiteLaxMode
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     policy.Path,
+		Domain:   policy.Domain,
+		MaxAge:   maxAge,
+		Expires:  expires,
+		Secure:   !policy.AllowInsecure,
+		HttpOnly: httpOnly,
+		SameSite: policy.SameSite,
+	}
+}
+
+// csrfCookieName retorna o nome do cookie CSRF, derivado do cookie de sessão
+func (a *Authenticator) csrfCookieName() string {
+	return a.cookieName + "_CSRF"
+}
+
+// csrfToken deriva o token CSRF do token de sessão
+func csrfToken(sessionToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RenewedTokenHeader é o header de resposta com o token renovado por
+// [WithSlidingSession], quando o token original não veio do cookie de sessão.
+const RenewedTokenHeader = "X-Renewed-Token"
+
+// slidingConfig agrupa a configuração de renovação de sessão
+type slidingConfig struct {
+	threshold float64
+	maxAge    time.Duration
+}
+
+// WithSlidingSession habilita a renovação automática de tokens no
+// [Authenticator.Middleware]. Quando mais de threshold (entre 0 e 1) da vida do
+// token já passou, um novo token com os mesmos dados e a mesma duração é emitido:
+//
+//   - se o token veio do cookie de sessão, o cookie é regravado;
+//   - caso contrário, o novo token é enviado no header X-Renewed-Token.
+//
+// maxAge limita a duração total da sessão, contada a partir do login (claim
+// auth_time, gravado por [Authenticator.Sign]). Após esse prazo o token não é
+// mais renovado e expira normalmente. Zero desabilita o limite.
+//
+// O token anterior continua válido até o seu exp.
+//
+//	a := auth.New("secret",
+//	    auth.WithCookieName("SESSION"),
+//	    auth.WithSlidingSession(0.5, 12*time.Hour),
+//	)
+func WithSlidingSession(threshold float64, maxAge time.Duration) Option {
+	return func(a *Authenticator) {
+		if threshold <= 0 || threshold >= 1 {
+			threshold = 0.5
+		}
+		a.sliding = &slidingConfig{
+			threshold: threshold,
+			maxAge:    maxAge,
+		}
+	}
+}
+
+// slide renova o token da requisição se ele passou do limiar configurado
+func (a *Authenticator) slide(w http.ResponseWriter, r *http.Request, claims *internalClaims) {
+	if a.sliding == nil || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return
+	}
+
+	now := a.now()
+	issuedAt, expiresAt := claims.IssuedAt.Time, claims.ExpiresAt.Time
+	lifetime := expiresAt.Sub(issuedAt)
+	if now.Sub(issuedAt) < time.Duration(float64(lifetime)*a.sliding.threshold) {
+		return
+	}
+
+	authTime := issuedAt
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	}
+	newExpiresAt := now.Add(lifetime)
+	if a.sliding.maxAge > 0 {
+		if limit := authTime.Add(a.sliding.maxAge); newExpiresAt.After(limit) {
+			newExpiresAt = limit
+		}
+	}
+	if !newExpiresAt.After(expiresAt) {
+		return
+	}
+
+	token, err := a.renew(claims, authTime, now, newExpiresAt)
+	if err != nil {
+		return
+	}
+
+	if a.cookieName != "" {
+		if _, err := r.Cookie(a.cookieName); err == nil {
+			a.SetSessionCookie(w, token, newExpiresAt.Sub(now))
+			return
+		}
+	}
+	w.Header().Set(RenewedTokenHeader, token)
+}
+
+// renew reassina os claims com novo jti, iat e exp, preservando o JSON original de "data"
+func (a *Authenticator) renew(claims *internalClaims, authTime, now, expiresAt time.Time) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	var data any = claims.Data
+	if claims.rawData != nil {
+		data = json.RawMessage(claims.rawData)
+	}
+
+	registered := claims.RegisteredClaims
+	registered.ID = jti
+	registered.IssuedAt = jwt.NewNumericDate(now)
+	registered.ExpiresAt = jwt.NewNumericDate(expiresAt)
+
+	return a.signClaims(signedClaims{
+		Data:             data,
+		AuthTime:         jwt.NewNumericDate(authTime),
+		RegisteredClaims: registered,
+	})
+}
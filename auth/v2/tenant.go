@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownTenant indica uma requisição sem tenant identificável ou com tenant não cadastrado.
+var ErrUnknownTenant = errors.New("tenant desconhecido")
+
+// TenantStore fornece o [Authenticator] de cada tenant, com suas próprias
+// chaves, emissores permitidos e demais opções.
+type TenantStore interface {
+	// Authenticator retorna o autenticador do tenant ou [ErrUnknownTenant].
+	Authenticator(ctx context.Context, tenantID string) (*Authenticator, error)
+}
+
+// StaticTenants é um [TenantStore] com os tenants configurados na inicialização.
+//
+//	tenants := auth.StaticTenants{
+//	    "acme":  auth.New(acmeSecret, auth.WithIssuer("https://acme.cgisoftware.com.br")),
+//	    "globex": auth.New("", auth.WithRemoteJWKS(globexJWKS, time.Hour)),
+//	}
+type StaticTenants map[string]*Authenticator
+
+// Authenticator busca o tenant no mapa, sem cópia: os [Authenticator] são
+// compartilhados entre as requisições.
+func (t StaticTenants) Authenticator(ctx context.Context, tenantID string) (*Authenticator, error) {
+	a, ok := t[tenantID]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return a, nil
+}
+
+// TenantResolver identifica o tenant da requisição. Retorna vazio quando não
+// consegue identificá-lo, passando a vez ao próximo resolver.
+type TenantResolver func(r *http.Request) string
+
+// TenantFromHeader identifica o tenant pelo header informado (ex.: "X-Tenant-ID").
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// TenantFromHost identifica o tenant pelo subdomínio de baseDomain:
+// com baseDomain "app.cgisoftware.com.br", o host "acme.app.cgisoftware.com.br"
+// resolve para "acme".
+func TenantFromHost(baseDomain string) TenantResolver {
+	suffix := "." + strings.TrimPrefix(baseDomain, ".")
+	return func(r *http.Request) string {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		tenant, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || strings.Contains(tenant, ".") {
+			return ""
+		}
+		return tenant
+	}
+}
+
+// TenantFromClaim identifica o tenant pelo campo dos claims do token, lido
+// antes da verificação. A assinatura é verificada em seguida com as chaves do
+// tenant resolvido, então um token forjado para outro tenant é rejeitado.
+// Use "iss" para identificar o tenant pelo emissor.
+//
+// O token é lido como no [Authenticator.Middleware]: cookie de sessão, cookie
+// do v1 e header Authorization. Informe em opts as opções de credencial dos
+// tenants para que sessões em cookie também sejam resolvidas:
+//
+//	auth.TenantFromClaim("tenant_id", auth.WithCookieName("SESSION"))
+func TenantFromClaim(field ContextValue, opts ...Option) TenantResolver {
+	reader := New("", opts...)
+	return func(r *http.Request) string {
+		tokenType, tokenString := extractToken(reader.credential(r))
+		if (tokenType != "" && tokenType != "Bearer") || tokenString == "" {
+			return ""
+		}
+
+		claims := &internalClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+			return ""
+		}
+		return tenantOf(claims, field)
+	}
+}
+
+// tenantOf retorna o tenant gravado no campo dos claims, ou o emissor para "iss"
+func tenantOf(claims *internalClaims, field ContextValue) string {
+	if field == "iss" {
+		return claims.Issuer
+	}
+	tenant, _ := claims.Data[field].(string)
+	return tenant
+}
+
+// tenantContextKey é a chave do contexto com o tenant da requisição
+type tenantContextKey struct{}
+
+// TenantFromContext retorna o tenant da requisição autenticada pelo
+// [MultiTenant.Middleware], para uso em outros pacotes (ex.: selecionar o
+// schema ou a conexão do tenant no postgres).
+//
+//	tenantID, ok := auth.TenantFromContext(r.Context())
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok
+}
+
+// MultiTenantOption configura um [MultiTenant].
+type MultiTenantOption func(*MultiTenant)
+
+// WithTenantResolvers define como o tenant é identificado, na ordem informada.
+// O padrão é TenantFromClaim("tenant_id").
+//
+//	auth.WithTenantResolvers(auth.TenantFromHost("app.cgisoftware.com.br"), auth.TenantFromHeader("X-Tenant-ID"))
+func WithTenantResolvers(resolvers ...TenantResolver) MultiTenantOption {
+	return func(m *MultiTenant) {
+		m.resolvers = resolvers
+	}
+}
+
+// WithTenantClaim define o campo dos claims que carrega o tenant. O padrão é
+// "tenant_id"; use "iss" quando o tenant for identificado pelo emissor.
+func WithTenantClaim(field ContextValue) MultiTenantOption {
+	return func(m *MultiTenant) {
+		m.tenantClaim = field
+	}
+}
+
+// MultiTenant autentica requisições de múltiplos tenants, cada um com seu
+// próprio [Authenticator]. Crie uma instância com [NewMultiTenant].
+type MultiTenant struct {
+	store       TenantStore
+	resolvers   []TenantResolver
+	tenantClaim ContextValue
+}
+
+// NewMultiTenant cria um [MultiTenant] sobre o store de tenants.
+//
+//	mt := auth.NewMultiTenant(tenants,
+//	    auth.WithTenantResolvers(auth.TenantFromHost("app.cgisoftware.com.br")),
+//	)
+//	r.Use(mt.Middleware("user_id"))
+func NewMultiTenant(store TenantStore, opts ...MultiTenantOption) *MultiTenant {
+	m := &MultiTenant{
+		store:       store,
+		tenantClaim: "tenant_id",
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if len(m.resolvers) == 0 {
+		m.resolvers = []TenantResolver{TenantFromClaim(m.tenantClaim)}
+	}
+	return m
+}
+
+// Sign emite um token com as chaves do tenant, gravando o tenant no claim
+// configurado em [WithTenantClaim].
+//
+//	token, err := mt.Sign(ctx, "acme", UserClaims{UserID: 1}, time.Hour)
+func (m *MultiTenant) Sign(ctx context.Context, tenantID string, claims CustomClaims, expireIn time.Duration, opts ...SignOption) (string, error) {
+	a, err := m.store.Authenticator(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	data := maps.Clone(claims.GetFields())
+	if data == nil {
+		data = make(map[ContextValue]any, 1)
+	}
+	data[m.tenantClaim] = tenantID
+	return a.Sign(fieldsClaims(data), expireIn, opts...)
+}
+
+// Middleware identifica o tenant, autentica a requisição com o [Authenticator]
+// do tenant (ver [Authenticator.Middleware]) e injeta o tenant no contexto,
+// recuperável com [TenantFromContext].
+//
+// Requisições sem tenant identificável, com tenant não cadastrado ou cujo token
+// não carrega o tenant resolvido no claim de [WithTenantClaim] recebem 401.
+// Credenciais Basic e API key não carregam claims: são validadas pelo
+// [Authenticator] do tenant resolvido e aceitas com esse tenant, que deve vir
+// de um resolver de header ou host, já que [TenantFromClaim] não o identifica.
+// Os middlewares de autorização do [Authenticator] do tenant podem ser usados
+// em seguida.
+func (m *MultiTenant) Middleware(values ...ContextValue) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := m.resolve(r)
+			if tenantID == "" {
+				m.unknownTenant(w)
+				return
+			}
+
+			a, err := m.store.Authenticator(r.Context(), tenantID)
+			if errors.Is(err, ErrUnknownTenant) {
+				m.unknownTenant(w)
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "erro ao carregar tenant")
+				return
+			}
+
+			// um token sem o claim de tenant é tratado como de outro tenant;
+			// Basic e API key já foram validados pelos stores do tenant
+			sameTenant := func(claims *internalClaims) error {
+				if !claims.local && tenantOf(claims, m.tenantClaim) != tenantID {
+					return fmt.Errorf("%w: token de outro tenant", ErrInvalidClaims)
+				}
+				return nil
+			}
+
+			a.middleware(sameTenant, values)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), tenantContextKey{}, tenantID)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})).ServeHTTP(w, r)
+		})
+	}
+}
+
+// resolve retorna o tenant do primeiro resolver que o identificar
+func (m *MultiTenant) resolve(r *http.Request) string {
+	for _, resolver := range m.resolvers {
+		if tenant := resolver(r); tenant != "" {
+			return tenant
+		}
+	}
+	return ""
+}
+
+// unknownTenant responde 401 para requisições sem tenant válido
+func (m *MultiTenant) unknownTenant(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, ErrUnknownTenant.Error())
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// typedClaimsKey é a chave do contexto para os claims tipados; cada T gera uma chave distinta
+type typedClaimsKey[T any] struct{}
+
+// TypedAuthenticator é um [Authenticator] parametrizado pela struct de claims T.
+// Os claims são serializados com as tags json de T e decodificados de volta no
+// mesmo tipo, sem passar por map[ContextValue]any: um int64 continua int64.
+//
+// Todos os métodos de [Authenticator] (JWKS, revogação, autorização...) continuam
+// disponíveis; apenas Sign e Middleware passam a trabalhar com T.
+//
+//	type UserClaims struct {
+//	    UserID int64  `json:"user_id"`
+//	    Role   string `json:"role"`
+//	}
+//
+//	a := auth.NewTyped[UserClaims]("secret")
+//	token, err := a.Sign(UserClaims{UserID: 1, Role: "admin"}, time.Hour)
+//
+//	r.Use(a.Middleware())
+//	user, ok := auth.GetClaims[UserClaims](r.Context())
+type TypedAuthenticator[T any] struct {
+	*Authenticator
+}
+
+// NewTyped cria um [TypedAuthenticator] com as mesmas opções de [New].
+func NewTyped[T any](secretKey string, opts ...Option) *TypedAuthenticator[T] {
+	return &TypedAuthenticator[T]{Authenticator: New(secretKey, opts...)}
+}
+
+// Sign gera e assina um token JWT com a struct de claims e o tempo de expiração.
+// Ver [Authenticator.Sign] para os claims registrados e as chaves de assinatura.
+func (t *TypedAuthenticator[T]) Sign(claims T, expireIn time.Duration, opts ...SignOption) (string, error) {
+	return t.sign(claims, expireIn, opts...)
+}
+
+// Middleware retorna um middleware HTTP que autentica a requisição e injeta os
+// claims decodificados em T no contexto, recuperáveis com [GetClaims].
+//
+// Requisições com Basic Auth recebem T preenchido a partir de client_id e secret.
+// Os valores não passam pelo [CryptService].
+func (t *TypedAuthenticator[T]) Middleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			claims, err := t.verifyToken(t.requestContext(request), t.credential(request))
+			if err != nil {
+				t.unauthorized(response, request, err)
+				return
+			}
+
+			typed, err := decodeClaims[T](claims)
+			if err != nil {
+				t.unauthorized(response, request, err)
+				return
+			}
+
+			t.slide(response, request, claims)
+			t.reportFlavor(request, claims)
+
+			ctx := t.contextWithClaims(request.Context(), claims, nil)
+			ctx = context.WithValue(ctx, typedClaimsKey[T]{}, typed)
+			next.ServeHTTP(response, request.WithContext(ctx))
+		})
+	}
+}
+
+// GetClaims recupera os claims tipados injetados pelo [TypedAuthenticator.Middleware].
+// Retorna o zero value e false se a requisição não foi autenticada com o mesmo T.
+//
+//	user, ok := auth.GetClaims[UserClaims](r.Context())
+func GetClaims[T any](ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(typedClaimsKey[T]{}).(T)
+	return v, ok
+}
+
+// decodeClaims decodifica o JSON original de "data" em T
+func decodeClaims[T any](claims *internalClaims) (T, error) {
+	var typed T
+
+	raw := claims.rawData
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(claims.Data); err != nil {
+			return typed, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+		}
+	}
+
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return typed, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return typed, nil
+}
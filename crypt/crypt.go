@@ -62,6 +62,11 @@ func LoadAESKeyFromPath(filePath string) ([]byte, error) {
 	return key, nil
 }
 
+// GenerateAESKey gera uma chave AES de 256 bits, por exemplo para [Keyring.Rotate]
+func GenerateAESKey() ([]byte, error) {
+	return generateAESKey()
+}
+
 // Criptografa dados com AES-GCM
 func encryptAES(aesKey, plaintext []byte) ([]byte, []byte, error) {
 	block, err := aes.NewCipher(aesKey)
@@ -175,9 +180,10 @@ func GenerateRSAKeyPairDefault() (*RSAKeyPair, error) {
 }
 
 // HybridEncryptWithKeys criptografa dados usando criptografia híbrida com chaves fornecidas
-// Função global que permite usar chaves RSA específicas sem precisar de um CryptService
+// Função global que permite usar chaves RSA específicas sem precisar de um CryptService.
+// O resultado é um [Envelope] AlgHybridRSAOAEP.
 func HybridEncryptWithKeys(data string, publicKey *rsa.PublicKey) (string, error) {
-	encrypted, err := HybridEncrypt(publicKey, []byte(data))
+	encrypted, err := sealHybrid(publicKey, []byte(data))
 	if err != nil {
 		return "", fmt.Errorf("erro ao criptografar dados: %v", err)
 	}
@@ -185,16 +191,17 @@ func HybridEncryptWithKeys(data string, publicKey *rsa.PublicKey) (string, error
 }
 
 // HybridDecryptWithKeys descriptografa dados usando criptografia híbrida com chaves fornecidas
-// Função global que permite usar chaves RSA específicas sem precisar de um CryptService
+// Função global que permite usar chaves RSA específicas sem precisar de um CryptService.
+// Aceita o [Envelope] e o formato anterior.
 func HybridDecryptWithKeys(encryptedData string, privateKey *rsa.PrivateKey) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
 		return []byte{}, fmt.Errorf("erro ao decodificar dados: %v", err)
 	}
 
-	decrypted, err := HybridDecrypt(privateKey, data)
+	decrypted, err := openHybridOrLegacy(privateKey, data)
 	if err != nil {
-		return []byte{}, fmt.Errorf("erro ao descriptografar dados: %v", err)
+		return []byte{}, fmt.Errorf("erro ao descriptografar dados: %w", err)
 	}
 	return decrypted, nil
 }
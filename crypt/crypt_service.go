@@ -1,23 +1,21 @@
 package crypt
 
 import (
+	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"crypto/rsa"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
-	"strings"
 )
 
 // CryptService encapsula operações de criptografia
 type CryptService struct {
-	privateKey  *rsa.PrivateKey
-	publicKey   *rsa.PublicKey
-	masterKey   []byte
-	rotationKey []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	keyring    *Keyring
+	provider   KeyProvider
 }
 
 // NewCryptService cria uma nova instância do serviço de criptografia
@@ -50,7 +48,7 @@ func Initialize(rsaPrivateKeyPath, rsaPublicKeyPath, aesMasterKeyPath, aesRotati
 		return CryptService{}, fmt.Errorf("erro ao carregar chave RSA pública: %v", err)
 	}
 
-	// Carrega chaves AES
+	// Carrega chaves AES: a master é a ativa e a de rotação continua aceita na descriptografia
 	masterKey, err := LoadAESKeyFromPath(aesMasterKeyPath)
 	if err != nil {
 		return CryptService{}, fmt.Errorf("erro ao carregar chave AES master: %v", err)
@@ -61,75 +59,387 @@ func Initialize(rsaPrivateKeyPath, rsaPublicKeyPath, aesMasterKeyPath, aesRotati
 		return CryptService{}, fmt.Errorf("erro ao carregar chave AES de rotação: %v", err)
 	}
 
+	keyring, err := NewKeyring(masterKey, rotationKey)
+	if err != nil {
+		return CryptService{}, err
+	}
+
+	return CryptService{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		keyring:    keyring,
+	}, nil
+}
+
+// InitializeWithKeyring cria o serviço com as chaves RSA dos caminhos
+// informados e as chaves AES do [Keyring], permitindo rotacioná-las sem
+// reiniciar o serviço.
+//
+//	keyring, err := crypt.LoadKeyringFromPaths("/keys/aes-2025-06.key", "/keys/aes-2025-01.key")
+//	service, err := crypt.InitializeWithKeyring(privPath, pubPath, keyring)
+func InitializeWithKeyring(rsaPrivateKeyPath, rsaPublicKeyPath string, keyring *Keyring) (CryptService, error) {
+	if keyring == nil {
+		return CryptService{}, fmt.Errorf("keyring AES é obrigatório")
+	}
+
+	privateKey, err := LoadRSAPrivateKeyFromPath(rsaPrivateKeyPath)
+	if err != nil {
+		return CryptService{}, fmt.Errorf("erro ao carregar chave RSA privada: %v", err)
+	}
+
+	publicKey, err := LoadRSAPublicKeyFromPath(rsaPublicKeyPath)
+	if err != nil {
+		return CryptService{}, fmt.Errorf("erro ao carregar chave RSA pública: %v", err)
+	}
+
 	return CryptService{
-		privateKey:  privateKey,
-		publicKey:   publicKey,
-		masterKey:   masterKey,
-		rotationKey: rotationKey,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		keyring:    keyring,
 	}, nil
 }
 
-// EncryptData criptografa dados usando criptografia híbrida
+// InitializeWithKeyProvider cria o serviço com as chaves RSA dos caminhos
+// informados e a criptografia simétrica feita por envelope: cada valor recebe
+// uma chave de dados própria, cifrada pela KEK do [KeyProvider].
+//
+//	provider, err := crypt.LoadLocalKeyProvider("/keys/kek.key")
+//	service, err := crypt.InitializeWithKeyProvider(privPath, pubPath, provider)
+func InitializeWithKeyProvider(rsaPrivateKeyPath, rsaPublicKeyPath string, provider KeyProvider) (CryptService, error) {
+	if provider == nil {
+		return CryptService{}, fmt.Errorf("provedor de chaves é obrigatório")
+	}
+
+	privateKey, err := LoadRSAPrivateKeyFromPath(rsaPrivateKeyPath)
+	if err != nil {
+		return CryptService{}, fmt.Errorf("erro ao carregar chave RSA privada: %v", err)
+	}
+
+	publicKey, err := LoadRSAPublicKeyFromPath(rsaPublicKeyPath)
+	if err != nil {
+		return CryptService{}, fmt.Errorf("erro ao carregar chave RSA pública: %v", err)
+	}
+
+	return CryptService{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		provider:   provider,
+	}, nil
+}
+
+// SetKeyProvider passa a criptografia simétrica do serviço para o [KeyProvider].
+// Os dados já cifrados com as chaves AES do [Keyring] continuam legíveis e
+// podem ser migrados com [CryptService.ReencryptTable].
+func (cs *CryptService) SetKeyProvider(provider KeyProvider) {
+	cs.provider = provider
+}
+
+// Keyring retorna o [Keyring] das chaves AES do serviço, para rotação. Nil
+// em serviços criados com [InitializeWithKeyProvider].
+func (cs *CryptService) Keyring() *Keyring {
+	return cs.keyring
+}
+
+// EncryptData criptografa dados usando criptografia híbrida, em um [Envelope]
+// AlgHybridRSAOAEP
 func (cs *CryptService) EncryptData(data string) (string, error) {
-	encrypted, err := HybridEncrypt(cs.publicKey, []byte(data))
+	encrypted, err := sealHybrid(cs.publicKey, []byte(data))
 	if err != nil {
 		return "", fmt.Errorf("erro ao criptografar dados: %v", err)
 	}
 	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
-// DecryptData descriptografa dados usando criptografia híbrida
+// DecryptData descriptografa dados usando criptografia híbrida. Aceita o
+// [Envelope] e o formato anterior (JSON de [EncryptedPayload]).
 func (cs *CryptService) DecryptData(encryptedData string) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
 		return []byte{}, fmt.Errorf("erro ao decodificar dados: %v", err)
 	}
 
-	decrypted, err := HybridDecrypt(cs.privateKey, data)
+	decrypted, err := openHybridOrLegacy(cs.privateKey, data)
 	if err != nil {
 		return []byte{}, fmt.Errorf("erro ao descriptografar dados: %v", err)
 	}
 	return decrypted, nil
 }
 
-// EncryptWithMasterKeySimple criptografa usando a chave mestra AES (mais simples)
+// EncryptWithMasterKeySimple criptografa com a chave simétrica do serviço. Com
+// [KeyProvider], gera um [Envelope] AlgWrappedKey; caso contrário, um
+// AlgAESGCM com a chave ativa do [Keyring] (a chave mestra, até a primeira rotação)
 func (cs *CryptService) EncryptWithMasterKeySimple(data string) (string, error) {
-	encrypted, err := EncryptWithMasterKey(cs.masterKey, []byte(data))
+	return cs.EncryptContext(context.Background(), data)
+}
+
+// EncryptContext é o equivalente de [CryptService.EncryptWithMasterKeySimple]
+// com contexto, repassado ao [KeyProvider].
+func (cs *CryptService) EncryptContext(ctx context.Context, data string) (string, error) {
+	encrypted, err := cs.encryptSymmetric(ctx, []byte(data))
 	if err != nil {
 		return "", err
 	}
 	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
-// DecryptWithMasterKeySimple descriptografa usando a chave mestra AES
+// DecryptWithMasterKeySimple descriptografa dados simétricos: envelopes do
+// [KeyProvider] ou do [Keyring] (chave ativa ou aposentada) e o formato
+// anterior (nonce||ciphertext).
 func (cs *CryptService) DecryptWithMasterKeySimple(encryptedData string) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
 		return []byte{}, fmt.Errorf("erro ao decodificar dados: %v", err)
 	}
 
-	decrypted, err := DecryptWithMasterKey(cs.masterKey, data)
+	decrypted, err := cs.decryptSymmetric(context.Background(), data)
 	if err != nil {
 		return []byte{}, err
 	}
 	return decrypted, nil
 }
 
+// Decrypt descriptografa dados produzidos por qualquer método de criptografia
+// do serviço, escolhendo o algoritmo e a chave pelo [Envelope]. Dados nos
+// formatos anteriores são reconhecidos pelo formato: JSON de
+// [EncryptedPayload] (híbrido) ou nonce||ciphertext (chave mestra).
+func (cs *CryptService) Decrypt(encryptedData string) ([]byte, error) {
+	return cs.DecryptContext(context.Background(), encryptedData)
+}
+
+// DecryptContext é o equivalente de [CryptService.Decrypt] com contexto,
+// repassado ao [KeyProvider].
+func (cs *CryptService) DecryptContext(ctx context.Context, encryptedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return []byte{}, fmt.Errorf("erro ao decodificar dados: %v", err)
+	}
+
+	env, err := ParseEnvelope(data)
+	hybrid := err == nil && env.Algorithm == AlgHybridRSAOAEP
+	var hybridErr error
+	if hybrid || (errors.Is(err, ErrNotEnvelope) && bytes.HasPrefix(data, []byte("{"))) {
+		decrypted, err := openHybridOrLegacy(cs.privateKey, data)
+		if err == nil {
+			return decrypted, nil
+		}
+		hybridErr = fmt.Errorf("erro ao descriptografar dados: %w", err)
+		if hybrid {
+			return []byte{}, hybridErr
+		}
+		// dados simétricos no formato anterior (nonce||ciphertext) podem,
+		// raramente, começar com '{'
+	}
+
+	decrypted, err := cs.decryptSymmetric(ctx, data)
+	if err != nil {
+		if hybridErr != nil {
+			return []byte{}, hybridErr
+		}
+		return []byte{}, err
+	}
+	return decrypted, nil
+}
+
+// Reencrypt criptografa novamente os dados com as chaves atuais do serviço:
+// dados simétricos com chave aposentada, no formato anterior ou do [Keyring]
+// após [CryptService.SetKeyProvider] passam para a chave ativa, e dados
+// híbridos no formato anterior passam para o [Envelope]. Retorna false, sem
+// alterar os dados, se já estão atualizados.
+func (cs *CryptService) Reencrypt(encryptedData string) (string, bool, error) {
+	return cs.reencrypt(context.Background(), encryptedData)
+}
+
+// reencrypt implementa Reencrypt com contexto
+func (cs *CryptService) reencrypt(ctx context.Context, encryptedData string) (string, bool, error) {
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return "", false, fmt.Errorf("erro ao decodificar dados: %v", err)
+	}
+
+	env, err := ParseEnvelope(data)
+	hybrid := err == nil && env.Algorithm == AlgHybridRSAOAEP
+	var hybridErr error
+	if hybrid || (errors.Is(err, ErrNotEnvelope) && bytes.HasPrefix(data, []byte("{"))) {
+		if hybrid && env.KeyID == RSAKeyID(cs.publicKey) {
+			return encryptedData, false, nil
+		}
+		plaintext, err := openHybridOrLegacy(cs.privateKey, data)
+		if err == nil {
+			reencrypted, err := sealHybrid(cs.publicKey, plaintext)
+			if err != nil {
+				return "", false, fmt.Errorf("erro ao criptografar dados: %v", err)
+			}
+			return base64.StdEncoding.EncodeToString(reencrypted), true, nil
+		}
+		hybridErr = fmt.Errorf("erro ao descriptografar dados: %w", err)
+		if hybrid {
+			return "", false, hybridErr
+		}
+		// dados simétricos no formato anterior podem começar com '{', como em DecryptContext
+	}
+
+	if cs.symmetricCurrent(data) {
+		return encryptedData, false, nil
+	}
+	plaintext, err := cs.decryptSymmetric(ctx, data)
+	if err != nil {
+		if hybridErr != nil {
+			return "", false, hybridErr
+		}
+		return "", false, err
+	}
+	reencrypted, err := cs.encryptSymmetric(ctx, plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return base64.StdEncoding.EncodeToString(reencrypted), true, nil
+}
+
+// encryptSymmetric criptografa com o [KeyProvider], se configurado, ou com o [Keyring]
+func (cs *CryptService) encryptSymmetric(ctx context.Context, plaintext []byte) ([]byte, error) {
+	if cs.provider != nil {
+		return sealWrapped(ctx, cs.provider, plaintext)
+	}
+	if cs.keyring == nil {
+		return nil, fmt.Errorf("chave AES não configurada")
+	}
+	return cs.keyring.Encrypt(plaintext)
+}
+
+// decryptSymmetric descriptografa envelopes AlgWrappedKey com o [KeyProvider]
+// e os demais dados simétricos com o [Keyring]
+func (cs *CryptService) decryptSymmetric(ctx context.Context, data []byte) ([]byte, error) {
+	if env, err := ParseEnvelope(data); err == nil && env.Algorithm == AlgWrappedKey {
+		if cs.provider == nil {
+			return nil, fmt.Errorf("%w: provedor de chaves não configurado", ErrUnknownKey)
+		}
+		return openWrapped(ctx, cs.provider, env)
+	}
+	if cs.keyring == nil {
+		return nil, fmt.Errorf("%w: chave AES não configurada", ErrUnknownKey)
+	}
+	return cs.keyring.Decrypt(data)
+}
+
+// symmetricCurrent informa se os dados já estão na chave simétrica ativa
+func (cs *CryptService) symmetricCurrent(data []byte) bool {
+	env, err := ParseEnvelope(data)
+	if err != nil {
+		return false
+	}
+	if cs.provider != nil {
+		return env.Algorithm == AlgWrappedKey && env.KeyID == cs.provider.ActiveKeyID()
+	}
+	return cs.keyring != nil && !cs.keyring.NeedsReencryption(data)
+}
+
+// openHybridOrLegacy descriptografa um envelope AlgHybridRSAOAEP ou o JSON de [EncryptedPayload]
+func openHybridOrLegacy(priv *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("chave RSA privada não configurada")
+	}
+
+	env, err := ParseEnvelope(data)
+	if errors.Is(err, ErrNotEnvelope) {
+		return HybridDecrypt(priv, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openHybrid(priv, env)
+}
+
 // CryptManager gerencia diferentes tipos de criptografia
 type CryptManager struct {
 	hybridService CryptService
+	passwords     *PasswordHasher
 }
 
-// EncryptPassword criptografa uma senha usando AES (recomendado para senhas)
+// NewCryptManager cria um [CryptManager] sobre o serviço, com hashes de senha
+// Argon2id nos parâmetros padrão (ver [CryptManager.SetPasswordHasher]).
+func NewCryptManager(service CryptService) *CryptManager {
+	return &CryptManager{hybridService: service}
+}
+
+// SetPasswordHasher define o [PasswordHasher] usado nas senhas.
+func (cm *CryptManager) SetPasswordHasher(hasher *PasswordHasher) {
+	cm.passwords = hasher
+}
+
+// HashPassword gera o hash da senha, para armazenamento.
+func (cm *CryptManager) HashPassword(password string) (string, error) {
+	return cm.passwordHasher().Hash(password)
+}
+
+// VerifyPassword verifica a senha contra o valor armazenado: um hash de
+// [CryptManager.HashPassword] ou uma senha criptografada pelo antigo
+// [CryptManager.EncryptPassword]. Retorna [ErrPasswordMismatch] se a senha não
+// conferir.
+//
+// Quando o valor armazenado deve ser substituído (senha criptografada ou hash
+// com parâmetros desatualizados), rehash traz o novo hash a ser gravado; caso
+// contrário, é vazio.
+//
+//	rehash, err := manager.VerifyPassword(senha, usuario.Senha)
+//	if err != nil {
+//	    return err
+//	}
+//	if rehash != "" {
+//	    usuario.Senha = rehash // grava o novo hash
+//	}
+func (cm *CryptManager) VerifyPassword(password, stored string) (rehash string, err error) {
+	if !IsPasswordHash(stored) {
+		return cm.MigratePassword(password, stored)
+	}
+
+	hasher := cm.passwordHasher()
+	if err := hasher.Verify(password, stored); err != nil {
+		return "", err
+	}
+	if !hasher.NeedsRehash(stored) {
+		return "", nil
+	}
+	return hasher.Hash(password)
+}
+
+// MigratePassword verifica a senha contra uma senha criptografada pelo antigo
+// [CryptManager.EncryptPassword] e, se conferir, retorna o hash que deve
+// substituí-la. Retorna [ErrPasswordMismatch] se a senha não conferir.
+func (cm *CryptManager) MigratePassword(password, encryptedPassword string) (string, error) {
+	decrypted, err := cm.hybridService.DecryptWithMasterKeySimple(encryptedPassword)
+	if err != nil {
+		return "", fmt.Errorf("erro ao descriptografar senha: %w", err)
+	}
+	if subtle.ConstantTimeCompare(decrypted, []byte(password)) != 1 {
+		return "", ErrPasswordMismatch
+	}
+	return cm.passwordHasher().Hash(password)
+}
+
+// EncryptPassword criptografa uma senha usando AES
+//
+// Deprecated: senhas criptografadas podem ser revertidas por quem tiver a
+// chave. Use [CryptManager.HashPassword] e migre as existentes com
+// [CryptManager.VerifyPassword].
 func (cm *CryptManager) EncryptPassword(password string) (string, error) {
 	return cm.hybridService.EncryptWithMasterKeySimple(password)
 }
 
 // DecryptPassword descriptografa uma senha
+//
+// Deprecated: use [CryptManager.VerifyPassword].
 func (cm *CryptManager) DecryptPassword(encryptedPassword string) ([]byte, error) {
 	return cm.hybridService.DecryptWithMasterKeySimple(encryptedPassword)
 }
 
+// passwordHasher retorna o [PasswordHasher] configurado ou o padrão
+func (cm *CryptManager) passwordHasher() *PasswordHasher {
+	if cm.passwords == nil {
+		return defaultPasswordHasher
+	}
+	return cm.passwords
+}
+
 // EncryptSensitiveData criptografa dados sensíveis usando criptografia híbrida
 func (cm *CryptManager) EncryptSensitiveData(data string) (string, error) {
 	return cm.hybridService.EncryptData(data)
@@ -151,105 +461,47 @@ func (cs *CryptService) GenerateRSAKeysDefault() (*RSAKeyPair, error) {
 	return GenerateRSAKeyPairDefault()
 }
 
-// HybridEncryptWithKeys criptografa dados usando criptografia híbrida com chaves fornecidas
+// HybridEncryptWithKeys criptografa dados usando criptografia híbrida com chaves fornecidas,
+// em um [Envelope] AlgHybridRSAOAEP
 func (cs *CryptService) HybridEncryptWithKeys(data string, publicKey *rsa.PublicKey) (string, error) {
-	encrypted, err := HybridEncrypt(publicKey, []byte(data))
+	encrypted, err := sealHybrid(publicKey, []byte(data))
 	if err != nil {
 		return "", fmt.Errorf("erro ao criptografar dados com chaves fornecidas: %v", err)
 	}
 	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
-// HybridDecryptWithKeys descriptografa dados usando criptografia híbrida com chaves fornecidas
+// HybridDecryptWithKeys descriptografa dados usando criptografia híbrida com chaves fornecidas.
+// Aceita o [Envelope] e o formato anterior.
 func (cs *CryptService) HybridDecryptWithKeys(encryptedData string, privateKey *rsa.PrivateKey) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(encryptedData)
 	if err != nil {
 		return []byte{}, fmt.Errorf("erro ao decodificar dados: %v", err)
 	}
 
-	decrypted, err := HybridDecrypt(privateKey, data)
+	decrypted, err := openHybridOrLegacy(privateKey, data)
 	if err != nil {
-		return []byte{}, fmt.Errorf("erro ao descriptografar dados com chaves fornecidas: %v", err)
+		return []byte{}, fmt.Errorf("erro ao descriptografar dados com chaves fornecidas: %w", err)
 	}
 	return decrypted, nil
 }
 
+// GenerateToken gera um token v2 autenticado, sem expiração e sem dados
+// associados. Equivale a [GenerateTokenV2] com [TokenOptions] vazio.
+//
+// Versões anteriores geravam tokens AES-CBC sem verificação de integridade;
+// esses tokens são lidos apenas por [DecryptTokenV2] com AllowLegacy.
+//
+// Deprecated: use [GenerateTokenV2].
 func GenerateToken(ctx context.Context, key []byte, data []byte) (string, error) {
-	// Gerar IV aleatório
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", fmt.Errorf("erro ao gerar IV aleatório: %w", err)
-	}
-
-	// Criar cipher
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("erro ao criar cipher: %w", err)
-	}
-
-	// CBC mode
-	mode := cipher.NewCBCEncrypter(block, iv)
-
-	// PKCS7 padding
-	padding := aes.BlockSize - len(data)%aes.BlockSize
-	padText := append(data, bytesRepeat(byte(padding), padding)...)
-
-	cipherText := make([]byte, len(padText))
-	mode.CryptBlocks(cipherText, padText)
-
-	return base64.StdEncoding.EncodeToString(cipherText) + "-" + base64.StdEncoding.EncodeToString(iv), nil
+	return GenerateTokenV2(ctx, key, data, TokenOptions{})
 }
 
+// DecryptToken verifica e decifra um token de [GenerateToken]. Equivale a
+// [DecryptTokenV2] com [TokenOptions] vazio: tokens CBC anteriores retornam
+// [ErrLegacyToken].
+//
+// Deprecated: use [DecryptTokenV2].
 func DecryptToken(ctx context.Context, key []byte, token string) ([]byte, error) {
-	parts := strings.Split(token, "-")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("token inválido, formato esperado 'cipher-iv'")
-	}
-
-	cipherText, err := base64.StdEncoding.DecodeString(parts[0])
-	if err != nil {
-		return nil, fmt.Errorf("erro ao decodificar cipherText: %w", err)
-	}
-
-	iv, err := base64.StdEncoding.DecodeString(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("erro ao decodificar IV: %w", err)
-	}
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao criar cipher: %w", err)
-	}
-
-	if len(cipherText)%aes.BlockSize != 0 {
-		return nil, fmt.Errorf("tamanho do cipherText inválido")
-	}
-
-	mode := cipher.NewCBCDecrypter(block, iv)
-	plainText := make([]byte, len(cipherText))
-	mode.CryptBlocks(plainText, cipherText)
-
-	if len(plainText) == 0 {
-		return nil, fmt.Errorf("plaintext vazio após descriptografia")
-	}
-	padding := int(plainText[len(plainText)-1])
-	if padding <= 0 || padding > aes.BlockSize || padding > len(plainText) {
-		return nil, fmt.Errorf("padding PKCS7 inválido")
-	}
-	for i := range padding {
-		if plainText[len(plainText)-1-i] != byte(padding) {
-			return nil, fmt.Errorf("padding PKCS7 inconsistente")
-		}
-	}
-
-	return plainText[:len(plainText)-padding], nil
-}
-
-// Função para gerar padding
-func bytesRepeat(b byte, count int) []byte {
-	result := make([]byte, count)
-	for i := range result {
-		result[i] = b
-	}
-	return result
+	return DecryptTokenV2(ctx, key, token, TokenOptions{})
 }
@@ -0,0 +1,636 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testService é um CryptService de teste com acesso às chaves AES do keyring
+type testService struct {
+	*CryptService
+	masterKey   []byte
+	rotationKey []byte
+}
+
+// newTestService cria um CryptService com chaves geradas em memória
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterKey, _ := generateAESKey()
+	rotationKey, _ := generateAESKey()
+	keyring, err := NewKeyring(masterKey, rotationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testService{
+		CryptService: &CryptService{
+			privateKey: priv,
+			publicKey:  &priv.PublicKey,
+			keyring:    keyring,
+		},
+		masterKey:   masterKey,
+		rotationKey: rotationKey,
+	}
+}
+
+// encryptLegacyBrace cifra no formato anterior (nonce||ciphertext) com um nonce
+// que começa com '{', como o JSON do formato híbrido anterior
+func encryptLegacyBrace(masterKey []byte) ([]byte, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	nonce[0] = '{'
+	return append(nonce, gcm.Seal(nil, nonce, []byte("segredo"), nil)...), nil
+}
+
+func TestEnvelope(t *testing.T) {
+	cs := newTestService(t)
+	encode := base64.StdEncoding.EncodeToString
+
+	legacyAES, err := EncryptWithMasterKey(cs.masterKey, []byte("segredo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyHybrid, err := HybridEncrypt(cs.publicKey, []byte("segredo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBrace, err := encryptLegacyBrace(cs.masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotation, err := sealAES(cs.rotationKey, []byte("segredo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aes, err := cs.EncryptWithMasterKeySimple("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hybrid, err := cs.EncryptData("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		encrypted string
+		decrypt   func(string) ([]byte, error)
+		algorithm Algorithm
+	}{
+		{name: "aes", encrypted: aes, decrypt: cs.DecryptWithMasterKeySimple, algorithm: AlgAESGCM},
+		{name: "aes pelo envelope", encrypted: aes, decrypt: cs.Decrypt, algorithm: AlgAESGCM},
+		{name: "chave de rotação", encrypted: encode(rotation), decrypt: cs.Decrypt, algorithm: AlgAESGCM},
+		{name: "híbrido", encrypted: hybrid, decrypt: cs.DecryptData, algorithm: AlgHybridRSAOAEP},
+		{name: "híbrido pelo envelope", encrypted: hybrid, decrypt: cs.Decrypt, algorithm: AlgHybridRSAOAEP},
+		{name: "aes no formato anterior", encrypted: encode(legacyAES), decrypt: cs.Decrypt},
+		{name: "aes no formato anterior pelo método aes", encrypted: encode(legacyAES), decrypt: cs.DecryptWithMasterKeySimple},
+		{name: "aes no formato anterior iniciado por {", encrypted: encode(legacyBrace), decrypt: cs.Decrypt},
+		{name: "híbrido no formato anterior", encrypted: encode(legacyHybrid), decrypt: cs.Decrypt},
+		{name: "híbrido no formato anterior pelo método híbrido", encrypted: encode(legacyHybrid), decrypt: cs.DecryptData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypted, err := tt.decrypt(tt.encrypted)
+			if err != nil || string(decrypted) != "segredo" {
+				t.Fatalf("decrypt() = %q, %v, want %q", decrypted, err, "segredo")
+			}
+
+			if tt.algorithm == 0 {
+				return
+			}
+			data, _ := base64.StdEncoding.DecodeString(tt.encrypted)
+			if env, err := ParseEnvelope(data); err != nil || env.Algorithm != tt.algorithm {
+				t.Errorf("ParseEnvelope() = %v, %v, want %s", env, err, tt.algorithm)
+			}
+		})
+	}
+}
+
+func TestEnvelopeRejected(t *testing.T) {
+	cs := newTestService(t)
+	other := newTestService(t)
+
+	sealed, err := sealAES(cs.masterKey, []byte("segredo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := ParseEnvelope(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.KeyID = AESKeyID(cs.rotationKey)
+	swapped := env.Marshal()
+
+	hybrid, err := other.EncryptData("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		encrypted string
+		decrypt   func(string) ([]byte, error)
+		reason    error
+	}{
+		{name: "chave desconhecida", encrypted: base64.StdEncoding.EncodeToString(sealed), decrypt: other.Decrypt, reason: ErrUnknownKey},
+		{name: "kid adulterado", encrypted: base64.StdEncoding.EncodeToString(swapped), decrypt: cs.Decrypt},
+		{name: "chave RSA de outro serviço", encrypted: hybrid, decrypt: cs.Decrypt, reason: ErrUnknownKey},
+		{name: "híbrido pelo método aes", encrypted: hybrid, decrypt: other.DecryptWithMasterKeySimple, reason: ErrUnsupportedAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.decrypt(tt.encrypted)
+			if err == nil {
+				t.Fatal("decrypt() error = nil, want error")
+			}
+			if tt.reason != nil && !errors.Is(err, tt.reason) {
+				t.Errorf("decrypt() error = %v, want %v", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDecryptionMiddlewareType(t *testing.T) {
+	cs := newTestService(t)
+	encrypted, err := cs.EncryptWithMasterKeySimple("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		decryptionType string
+		status         int
+	}{
+		{decryptionType: DecryptionAES, status: http.StatusOK},
+		{decryptionType: DecryptionAuto, status: http.StatusOK},
+		{decryptionType: DecryptionHybrid, status: http.StatusBadRequest},
+		{decryptionType: "", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.decryptionType, func(t *testing.T) {
+			dm := NewDecryptionMiddleware(cs.CryptService, []string{"cpf"}, tt.decryptionType)
+			handler := dm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"cpf":"`+encrypted+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, rec.Body.String(), tt.status)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != `{"cpf":"segredo"}` {
+				t.Errorf("body = %s, want campo descriptografado", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		encrypt func(cs *CryptService, masterKey []byte) (string, error)
+	}{
+		{name: "chave aposentada", encrypt: func(cs *CryptService, masterKey []byte) (string, error) {
+			return cs.EncryptWithMasterKeySimple("segredo")
+		}},
+		{name: "formato anterior", encrypt: func(cs *CryptService, masterKey []byte) (string, error) {
+			legacy, err := EncryptWithMasterKey(masterKey, []byte("segredo"))
+			return base64.StdEncoding.EncodeToString(legacy), err
+		}},
+		{name: "formato anterior iniciado por {", encrypt: func(cs *CryptService, masterKey []byte) (string, error) {
+			legacy, err := encryptLegacyBrace(masterKey)
+			return base64.StdEncoding.EncodeToString(legacy), err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestService(t)
+			encrypted, err := tt.encrypt(cs.CryptService, cs.masterKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newKey, _ := generateAESKey()
+			if err := cs.Keyring().Rotate(newKey); err != nil {
+				t.Fatal(err)
+			}
+			if err := cs.Keyring().Remove(AESKeyID(newKey)); !errors.Is(err, ErrActiveKey) {
+				t.Fatalf("Remove(ativa) error = %v, want %v", err, ErrActiveKey)
+			}
+
+			reencrypted, changed, err := cs.Reencrypt(encrypted)
+			if err != nil || !changed {
+				t.Fatalf("Reencrypt() = %v, %v, want true, nil", changed, err)
+			}
+			if _, changed, _ := cs.Reencrypt(reencrypted); changed {
+				t.Error("Reencrypt() de dados na chave ativa = true, want false")
+			}
+
+			if err := cs.Keyring().Remove(AESKeyID(cs.masterKey)); err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := cs.Decrypt(reencrypted)
+			if err != nil || string(decrypted) != "segredo" {
+				t.Errorf("Decrypt() = %q, %v, want %q", decrypted, err, "segredo")
+			}
+			if _, err := cs.Decrypt(encrypted); err == nil {
+				t.Error("Decrypt() com chave removida error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestReencryptTable(t *testing.T) {
+	cs := newTestService(t)
+	current, err := cs.EncryptWithMasterKeySimple("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := EncryptWithMasterKey(cs.masterKey, []byte("segredo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedLegacy := base64.StdEncoding.EncodeToString(legacy)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// chaves inteiras são comparadas como inteiros: em texto, "10" < "9"
+	first := `SELECT id, cpf FROM clientes WHERE cpf IS NOT NULL AND cpf <> '' ORDER BY id LIMIT $1`
+	next := `SELECT id, cpf FROM clientes WHERE cpf IS NOT NULL AND cpf <> '' AND id > $2 ORDER BY id LIMIT $1`
+	mock.ExpectQuery(first).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cpf"}).AddRow(int64(9), current))
+	mock.ExpectQuery(next).WithArgs(1, int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cpf"}).AddRow(int64(10), encodedLegacy))
+	mock.ExpectExec(`UPDATE clientes SET cpf = $1 WHERE id = $2 AND cpf = $3`).
+		WithArgs(sqlmock.AnyArg(), int64(10), encodedLegacy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(next).WithArgs(1, int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cpf"}))
+
+	result, err := cs.ReencryptTable(context.Background(), db, ReencryptConfig{Table: "clientes", Column: "cpf", BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ReencryptResult{Scanned: 2, Reencrypted: 1, LastKey: "10"}
+	if result != want {
+		t.Errorf("ReencryptTable() = %+v, want %+v", result, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyProvider(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kek, _ := GenerateAESKey()
+	keyring, err := NewKeyring(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaProvider, err := NewRSAKeyProvider(priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRSAKeyProvider(nil, nil); err == nil {
+		t.Error("NewRSAKeyProvider(nil, nil) error = nil, want error")
+	}
+
+	tests := []struct {
+		name     string
+		provider KeyProvider
+	}{
+		{name: "memória", provider: NewMemoryKeyProvider()},
+		{name: "local", provider: NewLocalKeyProvider(keyring)},
+		{name: "rsa", provider: rsaProvider},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestService(t)
+			before, err := cs.EncryptWithMasterKeySimple("segredo")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cs.SetKeyProvider(tt.provider)
+			encrypted, err := cs.EncryptWithMasterKeySimple("segredo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := base64.StdEncoding.DecodeString(encrypted)
+			if env, err := ParseEnvelope(data); err != nil || env.Algorithm != AlgWrappedKey || env.KeyID != tt.provider.ActiveKeyID() {
+				t.Fatalf("ParseEnvelope() = %v, %v, want %s com kid %s", env, err, AlgWrappedKey, tt.provider.ActiveKeyID())
+			}
+
+			for _, value := range []string{encrypted, before} {
+				decrypted, err := cs.Decrypt(value)
+				if err != nil || string(decrypted) != "segredo" {
+					t.Errorf("Decrypt() = %q, %v, want %q", decrypted, err, "segredo")
+				}
+			}
+
+			if _, changed, err := cs.Reencrypt(encrypted); err != nil || changed {
+				t.Errorf("Reencrypt(KEK ativa) = %v, %v, want false, nil", changed, err)
+			}
+			if _, changed, err := cs.Reencrypt(before); err != nil || !changed {
+				t.Errorf("Reencrypt(keyring) = %v, %v, want true, nil", changed, err)
+			}
+		})
+	}
+}
+
+func TestMemoryKeyProvider(t *testing.T) {
+	provider := NewMemoryKeyProvider()
+	cs := &CryptService{provider: provider}
+
+	first, err := cs.EncryptWithMasterKeySimple("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cs.EncryptWithMasterKeySimple("segredo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wraps, _ := provider.Calls(); wraps != 2 || first == second {
+		t.Fatalf("WrapKey chamado %d vezes, want uma chave de dados por valor", wraps)
+	}
+
+	if _, err := provider.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	reencrypted, changed, err := cs.Reencrypt(first)
+	if err != nil || !changed {
+		t.Fatalf("Reencrypt() = %v, %v, want true, nil", changed, err)
+	}
+
+	unavailable := errors.New("kms indisponível")
+	provider.Fail(unavailable)
+	if _, err := cs.Decrypt(reencrypted); !errors.Is(err, unavailable) {
+		t.Errorf("Decrypt() error = %v, want %v", err, unavailable)
+	}
+	provider.Fail(nil)
+
+	if decrypted, err := cs.Decrypt(reencrypted); err != nil || string(decrypted) != "segredo" {
+		t.Errorf("Decrypt() = %q, %v, want %q", decrypted, err, "segredo")
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon := PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1}
+	bcryptConfig := PasswordConfig{Algorithm: PasswordBcrypt, Cost: bcrypt.MinCost}
+
+	tests := []struct {
+		name   string
+		config PasswordConfig
+		prefix string
+		// changed é a configuração que deve exigir um novo hash
+		changed PasswordConfig
+	}{
+		{name: "argon2id", config: argon, prefix: "$argon2id$v=19$m=1024,t=1,p=1$", changed: PasswordConfig{Memory: 2048, Iterations: 1, Parallelism: 1}},
+		{name: "tamanho do salt", config: argon, prefix: "$argon2id$v=19$m=1024,t=1,p=1$", changed: PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 32}},
+		{name: "bcrypt", config: bcryptConfig, prefix: "$2a$04$", changed: PasswordConfig{Algorithm: PasswordBcrypt, Cost: bcrypt.MinCost + 1}},
+		{name: "troca de algoritmo", config: bcryptConfig, prefix: "$2a$04$", changed: argon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := NewPasswordHasher(tt.config)
+			hash, err := hasher.Hash("senha-secreta")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) || !IsPasswordHash(hash) {
+				t.Fatalf("Hash() = %q, want prefixo %q", hash, tt.prefix)
+			}
+
+			if err := hasher.Verify("senha-secreta", hash); err != nil {
+				t.Errorf("Verify() error = %v, want nil", err)
+			}
+			if err := NewPasswordHasher(tt.changed).Verify("senha-secreta", hash); err != nil {
+				t.Errorf("Verify() com outra configuração error = %v, want nil", err)
+			}
+			if err := hasher.Verify("senha-errada", hash); !errors.Is(err, ErrPasswordMismatch) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+			}
+
+			if hasher.NeedsRehash(hash) {
+				t.Error("NeedsRehash() = true, want false")
+			}
+			if !NewPasswordHasher(tt.changed).NeedsRehash(hash) {
+				t.Error("NeedsRehash() com outra configuração = false, want true")
+			}
+		})
+	}
+}
+
+func TestPasswordHashRejected(t *testing.T) {
+	hasher := NewPasswordHasher(PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1})
+
+	for _, hash := range []string{
+		"",
+		"senha-em-texto",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1000,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=4096,t=1,p=255$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$2a$04$curto",
+	} {
+		if err := hasher.Verify("senha", hash); !errors.Is(err, ErrInvalidPasswordHash) {
+			t.Errorf("Verify(%q) error = %v, want %v", hash, err, ErrInvalidPasswordHash)
+		}
+		if !hasher.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false, want true", hash)
+		}
+	}
+}
+
+func TestCryptManagerPassword(t *testing.T) {
+	cs := newTestService(t)
+	manager := NewCryptManager(*cs.CryptService)
+	manager.SetPasswordHasher(NewPasswordHasher(PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1}))
+
+	legacy, err := manager.EncryptPassword("senha-secreta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.VerifyPassword("senha-errada", legacy); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("VerifyPassword() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+
+	rehash, err := manager.VerifyPassword("senha-secreta", legacy)
+	if err != nil || !IsPasswordHash(rehash) {
+		t.Fatalf("VerifyPassword(legado) = %q, %v, want novo hash", rehash, err)
+	}
+	if again, err := manager.VerifyPassword("senha-secreta", rehash); err != nil || again != "" {
+		t.Errorf("VerifyPassword(hash atual) = %q, %v, want \"\", nil", again, err)
+	}
+
+	manager.SetPasswordHasher(NewPasswordHasher(PasswordConfig{Memory: 2048, Iterations: 1, Parallelism: 1}))
+	if again, err := manager.VerifyPassword("senha-secreta", rehash); err != nil || again == "" {
+		t.Errorf("VerifyPassword(parâmetros antigos) = %q, %v, want novo hash", again, err)
+	}
+}
+
+// vetores de token: chave 00..1f, nonce a0..ab; o CBC usa o vetor F.2.1 do
+// NIST SP 800-38A, com um bloco de padding PKCS7
+const (
+	tokenVectorNoExpiry = "v2.AAAAAAAAAACgoaKjpKWmp6ipqquTawlMN6JthVZXN2M29-lfnGN8VbqwLLv2-Q"
+	tokenVectorExpiry   = "v2.AAAAAGlVuQCgoaKjpKWmp6ipqquTawlMN6JthVZXAF-TxOxzikAxvoBfEyP5KQ"
+	tokenVectorLegacy   = "dkmrrIEZskbO6Y6bEukZfYlk4LFJwQt7aC5uOarrcxw=-AAECAwQFBgcICQoLDA0ODw=="
+)
+
+func tokenVectorKey() []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestTokenVectors(t *testing.T) {
+	ctx := context.Background()
+	legacyKey, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	legacyPlaintext, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172a")
+	expiresAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	beforeExpiry := func() time.Time { return expiresAt.Add(-time.Minute) }
+
+	tests := []struct {
+		name      string
+		key       []byte
+		token     string
+		opts      TokenOptions
+		want      []byte
+		expiresAt int64
+	}{
+		{name: "v2 sem expiração", key: tokenVectorKey(), token: tokenVectorNoExpiry, want: []byte("usuario:42")},
+		{
+			name: "v2 com expiração e dados associados", key: tokenVectorKey(), token: tokenVectorExpiry,
+			opts: TokenOptions{AssociatedData: []byte("reset-senha"), Now: beforeExpiry},
+			want: []byte("usuario:42"), expiresAt: expiresAt.Unix(),
+		},
+		{name: "cbc anterior", key: legacyKey, token: tokenVectorLegacy, opts: TokenOptions{AllowLegacy: true}, want: legacyPlaintext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptTokenV2(ctx, tt.key, tt.token, tt.opts)
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Fatalf("DecryptTokenV2() = %x, %v, want %x", got, err, tt.want)
+			}
+			if tt.opts.AllowLegacy {
+				return
+			}
+
+			aesGCM, _ := newGCM(tt.key)
+			nonce := []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab}
+			if token := sealToken(aesGCM, nonce, tt.expiresAt, tt.want, tt.opts.AssociatedData); token != tt.token {
+				t.Errorf("sealToken() = %s, want %s", token, tt.token)
+			}
+		})
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	ctx := context.Background()
+	key := tokenVectorKey()
+	legacyKey, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// altera o último byte do primeiro bloco, corrompendo o padding do segundo
+	cipherText, iv, _ := strings.Cut(tokenVectorLegacy, "-")
+	raw, _ := base64.StdEncoding.DecodeString(cipherText)
+	raw[15] ^= 0x01
+	badPadding := base64.StdEncoding.EncodeToString(raw) + "-" + iv
+
+	tampered := []byte(tokenVectorNoExpiry)
+	tampered[len(tampered)-1] = 'A'
+
+	tests := []struct {
+		name   string
+		key    []byte
+		token  string
+		opts   TokenOptions
+		reason error
+	}{
+		{name: "adulterado", key: key, token: string(tampered), reason: ErrInvalidToken},
+		{name: "dados associados diferentes", key: key, token: tokenVectorExpiry, opts: TokenOptions{AssociatedData: []byte("outro"), Now: func() time.Time { return now.Add(-time.Hour) }}, reason: ErrInvalidToken},
+		{name: "expirado", key: key, token: tokenVectorExpiry, opts: TokenOptions{AssociatedData: []byte("reset-senha"), Now: func() time.Time { return now }}, reason: ErrTokenExpired},
+		{name: "outra chave", key: legacyKey, token: tokenVectorNoExpiry, reason: ErrInvalidToken},
+		{name: "truncado", key: key, token: "v2.AAAA", reason: ErrInvalidToken},
+		{name: "cbc sem opt-in", key: legacyKey, token: tokenVectorLegacy, reason: ErrLegacyToken},
+		{name: "cbc com padding inválido", key: legacyKey, token: badPadding, opts: TokenOptions{AllowLegacy: true}, reason: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptTokenV2(ctx, tt.key, tt.token, tt.opts); !errors.Is(err, tt.reason) {
+				t.Errorf("DecryptTokenV2() error = %v, want %v", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	ctx := context.Background()
+	key, _ := GenerateAESKey()
+
+	token, err := GenerateToken(ctx, key, []byte("segredo"))
+	if err != nil || !strings.HasPrefix(token, "v2.") {
+		t.Fatalf("GenerateToken() = %q, %v, want token v2", token, err)
+	}
+	if got, err := DecryptToken(ctx, key, token); err != nil || string(got) != "segredo" {
+		t.Errorf("DecryptToken() = %q, %v, want %q", got, err, "segredo")
+	}
+
+	expiring, err := GenerateTokenV2(ctx, key, []byte("segredo"), TokenOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := TokenOptions{Now: func() time.Time { return time.Now().Add(2 * time.Minute) }}
+	if _, err := DecryptTokenV2(ctx, key, expiring, later); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("DecryptTokenV2() error = %v, want %v", err, ErrTokenExpired)
+	}
+}
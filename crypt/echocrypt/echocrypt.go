@@ -0,0 +1,31 @@
+// Package echocrypt adapta o [crypt.DecryptionMiddleware] para o Echo, sem que
+// o pacote crypt dependa do framework.
+package echocrypt
+
+import (
+	"net/http"
+
+	"github.com/cgisoftware/initializers/crypt"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware retorna um echo.MiddlewareFunc que descriptografa os campos
+// configurados no body JSON da requisição, com as mesmas regras de
+// [crypt.DecryptionMiddleware.Middleware]. Em caso de erro a resposta é
+// enviada e o handler não é chamado.
+//
+//	dm := crypt.NewDecryptionMiddleware(&service, []string{"cpf", "cartao"}, "hybrid")
+//	e := echo.New()
+//	e.Use(echocrypt.Middleware(dm))
+func Middleware(dm *crypt.DecryptionMiddleware) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			dm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
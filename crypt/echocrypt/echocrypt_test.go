@@ -0,0 +1,57 @@
+package echocrypt
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cgisoftware/initializers/crypt"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	var service crypt.CryptService
+	service.SetKeyProvider(crypt.NewMemoryKeyProvider())
+	encrypted, err := service.EncryptWithMasterKeySimple("123.456.789-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := echo.New()
+	handler.Use(Middleware(crypt.NewDecryptionMiddleware(&service, []string{"cpf"}, "aes")))
+	handler.POST("/", func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		return c.String(http.StatusOK, string(body))
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "campo criptografado", body: `{"cpf":"` + encrypted + `"}`, status: http.StatusOK, want: `{"cpf":"123.456.789-00"}`},
+		{name: "campo inválido", body: `{"cpf":"invalido"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, rec.Body.String(), tt.status)
+			}
+			if tt.want != "" && rec.Body.String() != tt.want {
+				t.Errorf("body = %s, want %s", rec.Body.String(), tt.want)
+			}
+			if tt.want == "" && !strings.Contains(rec.Body.String(), "Erro ao descriptografar dados") {
+				t.Errorf("body = %q, want erro de descriptografia", rec.Body.String())
+			}
+		})
+	}
+}
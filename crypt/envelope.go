@@ -0,0 +1,261 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// EnvelopeVersion é a versão atual do formato de [Envelope].
+const EnvelopeVersion byte = 1
+
+// envelopeMagic identifica um envelope e o distingue dos formatos anteriores
+// (nonce||ciphertext e o JSON de EncryptedPayload)
+var envelopeMagic = []byte("CG")
+
+var (
+	// ErrNotEnvelope indica dados em um formato anterior ao [Envelope].
+	ErrNotEnvelope = errors.New("dados não estão no formato de envelope")
+	// ErrUnknownKey indica um envelope cifrado com uma chave não disponível.
+	ErrUnknownKey = errors.New("chave do envelope não encontrada")
+	// ErrUnsupportedAlgorithm indica um algoritmo não suportado ou diferente do esperado.
+	ErrUnsupportedAlgorithm = errors.New("algoritmo do envelope não suportado")
+)
+
+// Algorithm identifica o algoritmo usado no conteúdo de um [Envelope].
+type Algorithm byte
+
+const (
+	// AlgAESGCM é AES-256-GCM com uma chave simétrica (chave mestra ou de rotação).
+	AlgAESGCM Algorithm = 1
+	// AlgHybridRSAOAEP é AES-256-GCM com a chave de conteúdo cifrada por RSA-OAEP (SHA-256).
+	AlgHybridRSAOAEP Algorithm = 2
+	// AlgWrappedKey é AES-256-GCM com uma chave de dados por registro, cifrada
+	// pela chave de criptografia de chaves (KEK) de um [KeyProvider].
+	AlgWrappedKey Algorithm = 3
+)
+
+// String retorna o nome do algoritmo
+func (a Algorithm) String() string {
+	switch a {
+	case AlgAESGCM:
+		return "AES-256-GCM"
+	case AlgHybridRSAOAEP:
+		return "RSA-OAEP+AES-256-GCM"
+	case AlgWrappedKey:
+		return "KEK+AES-256-GCM"
+	default:
+		return fmt.Sprintf("desconhecido(%d)", byte(a))
+	}
+}
+
+// Envelope é o formato autodescritivo produzido pelos métodos de criptografia
+// do [CryptService]:
+//
+//	"CG" | versão (1 byte) | algoritmo (1 byte) | tamanho do kid (1 byte) | kid | conteúdo
+//
+// O cabeçalho é autenticado pelo GCM como dado adicional, então alterar a
+// versão, o algoritmo ou o kid invalida o envelope.
+type Envelope struct {
+	Version   byte
+	Algorithm Algorithm
+	// KeyID identifica a chave usada: [AESKeyID] da chave simétrica, [RSAKeyID]
+	// da chave pública ou o kid da KEK do [KeyProvider].
+	KeyID string
+	// Payload é o conteúdo cifrado. Em AlgAESGCM: nonce||ciphertext. Em
+	// AlgHybridRSAOAEP e AlgWrappedKey: tamanho da chave cifrada (2 bytes) |
+	// chave cifrada | nonce | ciphertext.
+	Payload []byte
+}
+
+// ParseEnvelope decodifica um envelope. Dados em formatos anteriores retornam [ErrNotEnvelope].
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < len(envelopeMagic)+3 || !bytes.HasPrefix(data, envelopeMagic) {
+		return nil, ErrNotEnvelope
+	}
+
+	rest := data[len(envelopeMagic):]
+	env := &Envelope{Version: rest[0], Algorithm: Algorithm(rest[1])}
+	if env.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("%w: versão %d", ErrNotEnvelope, env.Version)
+	}
+	if env.Algorithm != AlgAESGCM && env.Algorithm != AlgHybridRSAOAEP && env.Algorithm != AlgWrappedKey {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, env.Algorithm)
+	}
+
+	kidLen := int(rest[2])
+	rest = rest[3:]
+	if len(rest) < kidLen {
+		return nil, fmt.Errorf("%w: kid truncado", ErrNotEnvelope)
+	}
+	env.KeyID = string(rest[:kidLen])
+	env.Payload = rest[kidLen:]
+	return env, nil
+}
+
+// Marshal serializa o envelope.
+func (e *Envelope) Marshal() []byte {
+	return append(e.header(), e.Payload...)
+}
+
+// header retorna o cabeçalho do envelope, autenticado como dado adicional do GCM
+func (e *Envelope) header() []byte {
+	header := make([]byte, 0, len(envelopeMagic)+3+len(e.KeyID))
+	header = append(header, envelopeMagic...)
+	header = append(header, e.Version, byte(e.Algorithm), byte(len(e.KeyID)))
+	return append(header, e.KeyID...)
+}
+
+// AESKeyID retorna o identificador de uma chave simétrica: os 8 primeiros bytes
+// do SHA-256 da chave, em hexadecimal.
+func AESKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// RSAKeyID retorna o identificador de uma chave RSA: os 8 primeiros bytes do
+// SHA-256 da chave pública em DER (PKIX), em hexadecimal.
+func RSAKeyID(pub *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// sealAES cifra com AES-GCM em um envelope AlgAESGCM
+func sealAES(key []byte, plaintext []byte) ([]byte, error) {
+	env := &Envelope{Version: EnvelopeVersion, Algorithm: AlgAESGCM, KeyID: AESKeyID(key)}
+
+	nonce, ciphertext, err := sealGCM(key, plaintext, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("erro na criptografia AES: %v", err)
+	}
+	env.Payload = append(nonce, ciphertext...)
+	return env.Marshal(), nil
+}
+
+// openAES descriptografa um envelope AlgAESGCM com a chave informada
+func openAES(key []byte, env *Envelope) ([]byte, error) {
+	if env.Algorithm != AlgAESGCM {
+		return nil, fmt.Errorf("%w: esperado %s, obtido %s", ErrUnsupportedAlgorithm, AlgAESGCM, env.Algorithm)
+	}
+
+	plaintext, err := openGCM(key, env.Payload, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("erro na descriptografia AES: %v", err)
+	}
+	return plaintext, nil
+}
+
+// sealHybrid cifra com uma chave AES aleatória, protegida por RSA-OAEP, em um
+// envelope AlgHybridRSAOAEP
+func sealHybrid(pub *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	env := &Envelope{Version: EnvelopeVersion, Algorithm: AlgHybridRSAOAEP, KeyID: RSAKeyID(pub)}
+
+	aesKey, err := generateAESKey()
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := encryptRSA(pub, aesKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := sealGCM(aesKey, plaintext, env.header())
+	if err != nil {
+		return nil, err
+	}
+
+	env.Payload = joinKeyPayload(encKey, nonce, ciphertext)
+	return env.Marshal(), nil
+}
+
+// openHybrid descriptografa um envelope AlgHybridRSAOAEP com a chave privada informada
+func openHybrid(priv *rsa.PrivateKey, env *Envelope) ([]byte, error) {
+	if env.Algorithm != AlgHybridRSAOAEP {
+		return nil, fmt.Errorf("%w: esperado %s, obtido %s", ErrUnsupportedAlgorithm, AlgHybridRSAOAEP, env.Algorithm)
+	}
+	if env.KeyID != RSAKeyID(&priv.PublicKey) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, env.KeyID)
+	}
+
+	encKey, sealed, err := splitKeyPayload(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	aesKey, err := decryptRSA(priv, encKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao descriptografar chave AES: %v", err)
+	}
+	plaintext, err := openGCM(aesKey, sealed, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("erro ao descriptografar dados: %v", err)
+	}
+	return plaintext, nil
+}
+
+// joinKeyPayload monta o conteúdo de um envelope com chave cifrada
+func joinKeyPayload(encKey, nonce, ciphertext []byte) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(encKey)))
+	payload = append(payload, encKey...)
+	payload = append(payload, nonce...)
+	return append(payload, ciphertext...)
+}
+
+// splitKeyPayload separa a chave cifrada de nonce||ciphertext
+func splitKeyPayload(payload []byte) ([]byte, []byte, error) {
+	if len(payload) < 2 {
+		return nil, nil, fmt.Errorf("envelope truncado")
+	}
+	keyLen := int(binary.BigEndian.Uint16(payload))
+	payload = payload[2:]
+	if len(payload) < keyLen {
+		return nil, nil, fmt.Errorf("envelope truncado")
+	}
+	return payload[:keyLen], payload[keyLen:], nil
+}
+
+// sealGCM cifra com AES-GCM, autenticando additionalData
+func sealGCM(key, plaintext, additionalData []byte) ([]byte, []byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aesGCM.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// openGCM descriptografa nonce||ciphertext com AES-GCM, verificando additionalData
+func openGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aesGCM.NonceSize() {
+		return nil, fmt.Errorf("dados criptografados muito pequenos")
+	}
+	nonce, ciphertext := sealed[:aesGCM.NonceSize()], sealed[aesGCM.NonceSize():]
+	return aesGCM.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// newGCM cria o AEAD AES-GCM para a chave
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -145,26 +145,27 @@ func ExampleCryptManager() {
 	}
 
 	// Criar gerenciador
-	manager := &CryptManager{hybridService: cryptService}
+	manager := NewCryptManager(cryptService)
 
-	// Criptografar senha
+	// Gerar hash da senha
 	password := "minhaSenhaSegura123!"
-	encryptedPassword, err := manager.EncryptPassword(password)
+	hashedPassword, err := manager.HashPassword(password)
 	if err != nil {
-		log.Printf("Erro ao criptografar senha: %v", err)
+		log.Printf("Erro ao gerar hash da senha: %v", err)
 		return
 	}
 
-	fmt.Printf("Senha criptografada: %s\n", encryptedPassword)
+	fmt.Printf("Hash da senha: %s\n", hashedPassword)
 
-	// Descriptografar senha
-	decryptedPassword, err := manager.DecryptPassword(encryptedPassword)
+	// Verificar senha
+	rehash, err := manager.VerifyPassword(password, hashedPassword)
 	if err != nil {
-		log.Printf("Erro ao descriptografar senha: %v", err)
+		log.Printf("Senha inválida: %v", err)
 		return
 	}
-
-	fmt.Printf("Senha descriptografada: %s\n", string(decryptedPassword))
+	if rehash != "" {
+		fmt.Printf("Novo hash a ser gravado: %s\n", rehash)
+	}
 
 	// Criptografar dados sensíveis
 	sensitiveInfo := "CPF: 123.456.789-00, RG: 12.345.678-9"
@@ -0,0 +1,33 @@
+// Package gincrypt adapta o [crypt.DecryptionMiddleware] para o Gin, sem que o
+// pacote crypt dependa do framework.
+package gincrypt
+
+import (
+	"net/http"
+
+	"github.com/cgisoftware/initializers/crypt"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware retorna um gin.HandlerFunc que descriptografa os campos
+// configurados no body JSON da requisição, com as mesmas regras de
+// [crypt.DecryptionMiddleware.Middleware]. Em caso de erro a resposta é
+// enviada e a cadeia é interrompida.
+//
+//	dm := crypt.NewDecryptionMiddleware(&service, []string{"cpf", "cartao"}, "hybrid")
+//	r := gin.Default()
+//	r.Use(gincrypt.Middleware(dm))
+func Middleware(dm *crypt.DecryptionMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		passed := false
+		dm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+
+		if !passed {
+			c.Abort()
+		}
+	}
+}
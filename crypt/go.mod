@@ -1,3 +1,10 @@
 module github.com/cgisoftware/initializers/crypt
 
 go 1.25.4
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	golang.org/x/crypto v0.45.0
+)
+
+require golang.org/x/sys v0.38.0 // indirect
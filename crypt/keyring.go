@@ -0,0 +1,209 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrActiveKey indica uma tentativa de remover a chave ativa do [Keyring].
+var ErrActiveKey = errors.New("a chave ativa não pode ser removida")
+
+// Keyring guarda as chaves AES do serviço: uma chave ativa, usada para
+// criptografar, e chaves aposentadas, usadas apenas para descriptografar
+// dados ainda não migrados. Cada chave é identificada por [AESKeyID], gravado
+// no [Envelope].
+//
+// O Keyring é seguro para uso concorrente: [Keyring.Rotate] troca a chave
+// ativa sem reiniciar o serviço.
+type Keyring struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string][]byte
+	// order preserva a ordem das chaves para a leitura do formato anterior, sem kid
+	order []string
+}
+
+// NewKeyring cria um [Keyring] com a chave ativa e as chaves aposentadas informadas.
+//
+//	keyring, err := crypt.NewKeyring(chaveAtual, chaveAnterior)
+func NewKeyring(active []byte, retired ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, key := range append([][]byte{active}, retired...) {
+		if err := k.add(key); err != nil {
+			return nil, err
+		}
+	}
+	k.active = AESKeyID(active)
+	return k, nil
+}
+
+// LoadKeyringFromPaths carrega o [Keyring] de arquivos no formato de
+// [LoadAESKeyFromPath]: a chave ativa e as chaves aposentadas.
+func LoadKeyringFromPaths(activePath string, retiredPaths ...string) (*Keyring, error) {
+	active, err := LoadAESKeyFromPath(activePath)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar chave AES ativa: %v", err)
+	}
+
+	retired := make([][]byte, 0, len(retiredPaths))
+	for _, path := range retiredPaths {
+		key, err := LoadAESKeyFromPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar chave AES aposentada: %v", err)
+		}
+		retired = append(retired, key)
+	}
+	return NewKeyring(active, retired...)
+}
+
+// ActiveKeyID retorna o kid da chave ativa.
+func (k *Keyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// KeyIDs retorna os kids de todas as chaves, a ativa primeiro.
+func (k *Keyring) KeyIDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ids := []string{k.active}
+	for _, id := range k.order {
+		if id != k.active {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Rotate torna newKey a chave ativa. A chave ativa anterior passa a ser
+// aposentada e continua descriptografando os dados existentes até ser
+// removida com [Keyring.Remove], após a re-criptografia (ver [CryptService.ReencryptTable]).
+func (k *Keyring) Rotate(newKey []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.add(newKey); err != nil {
+		return err
+	}
+	k.active = AESKeyID(newKey)
+	return nil
+}
+
+// Remove descarta uma chave aposentada. Dados ainda cifrados com ela deixam de
+// ser legíveis.
+func (k *Keyring) Remove(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if kid == k.active {
+		return ErrActiveKey
+	}
+	delete(k.keys, kid)
+	for i, id := range k.order {
+		if id == kid {
+			k.order = append(k.order[:i], k.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Encrypt criptografa com a chave ativa, em um [Envelope] AlgAESGCM.
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	key, _ := k.key(k.ActiveKeyID())
+	return sealAES(key, plaintext)
+}
+
+// Decrypt descriptografa com a chave indicada no [Envelope]. Dados no formato
+// anterior (nonce||ciphertext, sem kid) são testados com cada chave, a ativa primeiro.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	env, err := ParseEnvelope(data)
+	if errors.Is(err, ErrNotEnvelope) {
+		return k.decryptLegacy(data)
+	}
+	if err == nil {
+		key, ok := k.key(env.KeyID)
+		if env.Algorithm != AlgAESGCM {
+			err = fmt.Errorf("%w: esperado %s, obtido %s", ErrUnsupportedAlgorithm, AlgAESGCM, env.Algorithm)
+		} else if !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownKey, env.KeyID)
+		} else if plaintext, openErr := openAES(key, env); openErr != nil {
+			err = openErr
+		} else {
+			return plaintext, nil
+		}
+	}
+
+	// dados no formato anterior podem, raramente, começar com o prefixo do envelope
+	if plaintext, legacyErr := k.decryptLegacy(data); legacyErr == nil {
+		return plaintext, nil
+	}
+	return nil, err
+}
+
+// NeedsReencryption informa se os dados não estão cifrados com a chave ativa,
+// seja por usarem uma chave aposentada ou o formato anterior.
+func (k *Keyring) NeedsReencryption(data []byte) bool {
+	env, err := ParseEnvelope(data)
+	return err != nil || env.Algorithm != AlgAESGCM || env.KeyID != k.ActiveKeyID()
+}
+
+// Reencrypt descriptografa os dados e os criptografa novamente com a chave
+// ativa. Retorna false, sem alterar os dados, se já estão na chave ativa.
+func (k *Keyring) Reencrypt(data []byte) ([]byte, bool, error) {
+	if !k.NeedsReencryption(data) {
+		return data, false, nil
+	}
+
+	plaintext, err := k.Decrypt(data)
+	if err != nil {
+		return nil, false, err
+	}
+	reencrypted, err := k.Encrypt(plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return reencrypted, true, nil
+}
+
+// add inclui a chave no keyring, validando o tamanho
+func (k *Keyring) add(key []byte) error {
+	if len(key) != AESKeySize {
+		return fmt.Errorf("tamanho de chave inválido: esperado %d bytes, obtido %d bytes", AESKeySize, len(key))
+	}
+
+	id := AESKeyID(key)
+	if _, exists := k.keys[id]; !exists {
+		k.order = append(k.order, id)
+	}
+	k.keys[id] = key
+	return nil
+}
+
+// key retorna a chave com o kid informado
+func (k *Keyring) key(kid string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// decryptLegacy descriptografa nonce||ciphertext testando cada chave
+func (k *Keyring) decryptLegacy(data []byte) ([]byte, error) {
+	var err error
+	for _, kid := range k.KeyIDs() {
+		key, ok := k.key(kid)
+		if !ok {
+			continue
+		}
+		var plaintext []byte
+		if plaintext, err = DecryptWithMasterKey(key, data); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,283 @@
+package crypt
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+)
+
+// KeyProvider guarda a chave de criptografia de chaves (KEK) usada na
+// criptografia de envelope: cada registro recebe uma chave de dados própria,
+// gerada localmente e cifrada (wrapped) pela KEK, que nunca sai do provedor.
+//
+// O pacote traz [LocalKeyProvider] (chaves AES em arquivo), [RSAKeyProvider]
+// (chaves RSA em PEM) e [MemoryKeyProvider] (testes). Provedores de KMS em
+// nuvem implementam a mesma interface.
+type KeyProvider interface {
+	// ActiveKeyID retorna o kid da KEK usada em novas criptografias.
+	ActiveKeyID() string
+	// WrapKey cifra a chave de dados com a KEK ativa e retorna o kid da KEK usada.
+	WrapKey(ctx context.Context, dataKey []byte) (kid string, wrapped []byte, err error)
+	// UnwrapKey decifra a chave de dados com a KEK kid. Retorna [ErrUnknownKey]
+	// se a KEK não existir.
+	UnwrapKey(ctx context.Context, kid string, wrapped []byte) ([]byte, error)
+}
+
+// sealWrapped cifra com uma chave de dados nova, protegida pelo provedor, em
+// um envelope AlgWrappedKey
+func sealWrapped(ctx context.Context, provider KeyProvider, plaintext []byte) ([]byte, error) {
+	dataKey, err := generateAESKey()
+	if err != nil {
+		return nil, err
+	}
+	kid, wrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao cifrar chave de dados: %w", err)
+	}
+	if len(kid) > 255 {
+		return nil, fmt.Errorf("kid da KEK excede 255 bytes")
+	}
+
+	env := &Envelope{Version: EnvelopeVersion, Algorithm: AlgWrappedKey, KeyID: kid}
+	nonce, ciphertext, err := sealGCM(dataKey, plaintext, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("erro na criptografia AES: %v", err)
+	}
+	env.Payload = joinKeyPayload(wrapped, nonce, ciphertext)
+	return env.Marshal(), nil
+}
+
+// openWrapped descriptografa um envelope AlgWrappedKey com a chave de dados
+// decifrada pelo provedor
+func openWrapped(ctx context.Context, provider KeyProvider, env *Envelope) ([]byte, error) {
+	wrapped, sealed, err := splitKeyPayload(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := provider.UnwrapKey(ctx, env.KeyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decifrar chave de dados: %w", err)
+	}
+	plaintext, err := openGCM(dataKey, sealed, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("erro na descriptografia AES: %v", err)
+	}
+	return plaintext, nil
+}
+
+// LocalKeyProvider é um [KeyProvider] com KEKs AES-256 locais, guardadas em um
+// [Keyring]: a chave ativa cifra as chaves de dados e as aposentadas continuam
+// decifrando as existentes.
+type LocalKeyProvider struct {
+	keyring *Keyring
+}
+
+// NewLocalKeyProvider cria um [LocalKeyProvider] sobre o keyring.
+func NewLocalKeyProvider(keyring *Keyring) *LocalKeyProvider {
+	return &LocalKeyProvider{keyring: keyring}
+}
+
+// LoadLocalKeyProvider cria um [LocalKeyProvider] com as KEKs dos arquivos no
+// formato de [LoadAESKeyFromPath]: a ativa e as aposentadas.
+//
+//	provider, err := crypt.LoadLocalKeyProvider("/keys/kek-2025-06.key", "/keys/kek-2025-01.key")
+func LoadLocalKeyProvider(activePath string, retiredPaths ...string) (*LocalKeyProvider, error) {
+	keyring, err := LoadKeyringFromPaths(activePath, retiredPaths...)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalKeyProvider(keyring), nil
+}
+
+// Keyring retorna o [Keyring] das KEKs, para rotação.
+func (p *LocalKeyProvider) Keyring() *Keyring {
+	return p.keyring
+}
+
+// ActiveKeyID retorna o kid da KEK ativa do keyring, que muda após
+// [Keyring.Rotate].
+func (p *LocalKeyProvider) ActiveKeyID() string {
+	return p.keyring.ActiveKeyID()
+}
+
+// WrapKey cifra a chave de dados com AES-GCM sob a KEK ativa, autenticando o
+// kid como dado adicional. Não acessa a rede.
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	kid := p.keyring.ActiveKeyID()
+	kek, _ := p.keyring.key(kid)
+	nonce, wrapped, err := sealGCM(kek, dataKey, []byte(kid))
+	if err != nil {
+		return "", nil, err
+	}
+	return kid, append(nonce, wrapped...), nil
+}
+
+// UnwrapKey decifra com qualquer KEK do keyring, ativa ou aposentada.
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, kid string, wrapped []byte) ([]byte, error) {
+	kek, ok := p.keyring.key(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return openGCM(kek, wrapped, []byte(kid))
+}
+
+// RSAKeyProvider é um [KeyProvider] com a KEK em um par de chaves RSA: as
+// chaves de dados são cifradas com RSA-OAEP (SHA-256). Sem a chave privada,
+// apenas criptografa.
+type RSAKeyProvider struct {
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	kid        string
+}
+
+// NewRSAKeyProvider cria um [RSAKeyProvider] com as chaves informadas.
+// privateKey pode ser nil em serviços que apenas criptografam; publicKey pode
+// ser nil se privateKey for informada. Sem nenhuma das duas, retorna erro.
+func NewRSAKeyProvider(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey) (*RSAKeyProvider, error) {
+	if publicKey == nil && privateKey != nil {
+		publicKey = &privateKey.PublicKey
+	}
+	if publicKey == nil {
+		return nil, fmt.Errorf("chave RSA não informada")
+	}
+	return &RSAKeyProvider{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		kid:        RSAKeyID(publicKey),
+	}, nil
+}
+
+// LoadRSAKeyProviderFromPaths cria um [RSAKeyProvider] com as chaves PEM dos
+// caminhos informados. Use "" em rsaPrivateKeyPath para apenas criptografar.
+func LoadRSAKeyProviderFromPaths(rsaPrivateKeyPath, rsaPublicKeyPath string) (*RSAKeyProvider, error) {
+	var privateKey *rsa.PrivateKey
+	if rsaPrivateKeyPath != "" {
+		var err error
+		if privateKey, err = LoadRSAPrivateKeyFromPath(rsaPrivateKeyPath); err != nil {
+			return nil, fmt.Errorf("erro ao carregar chave RSA privada: %v", err)
+		}
+	}
+
+	publicKey, err := LoadRSAPublicKeyFromPath(rsaPublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar chave RSA pública: %v", err)
+	}
+	return NewRSAKeyProvider(privateKey, publicKey)
+}
+
+// ActiveKeyID retorna o kid derivado da chave pública, fixo durante a vida do
+// provedor.
+func (p *RSAKeyProvider) ActiveKeyID() string {
+	return p.kid
+}
+
+// WrapKey cifra a chave de dados com RSA-OAEP usando apenas a chave pública.
+func (p *RSAKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := encryptRSA(p.publicKey, dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.kid, wrapped, nil
+}
+
+// UnwrapKey decifra com a chave privada. Retorna [ErrUnknownKey] para outro
+// kid ou quando o provedor foi criado sem a chave privada.
+func (p *RSAKeyProvider) UnwrapKey(ctx context.Context, kid string, wrapped []byte) ([]byte, error) {
+	if kid != p.kid || p.privateKey == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return decryptRSA(p.privateKey, wrapped)
+}
+
+// MemoryKeyProvider é um [KeyProvider] em memória para testes: as KEKs são
+// geradas na criação e em [MemoryKeyProvider.Rotate], e [MemoryKeyProvider.Fail]
+// simula a indisponibilidade do KMS.
+type MemoryKeyProvider struct {
+	mu     sync.Mutex
+	keys   map[string][]byte
+	active string
+	err    error
+	// wraps e unwraps contam as chamadas ao provedor
+	wraps, unwraps int
+}
+
+// NewMemoryKeyProvider cria um [MemoryKeyProvider] com uma KEK aleatória. Se
+// a KEK não puder ser gerada, as chamadas ao provedor retornam o erro.
+func NewMemoryKeyProvider() *MemoryKeyProvider {
+	p := &MemoryKeyProvider{keys: make(map[string][]byte)}
+	if _, err := p.Rotate(); err != nil {
+		p.err = err
+	}
+	return p
+}
+
+// Rotate gera uma nova KEK ativa, mantendo as anteriores, e retorna o seu kid.
+func (p *MemoryKeyProvider) Rotate() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kek, err := generateAESKey()
+	if err != nil {
+		return "", fmt.Errorf("erro ao gerar KEK: %v", err)
+	}
+	p.active = fmt.Sprintf("memory-%d", len(p.keys)+1)
+	p.keys[p.active] = kek
+	return p.active, nil
+}
+
+// Calls retorna o número de chamadas a WrapKey e UnwrapKey.
+func (p *MemoryKeyProvider) Calls() (wraps, unwraps int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.wraps, p.unwraps
+}
+
+// Fail faz as chamadas seguintes retornarem err. Use nil para restabelecer.
+func (p *MemoryKeyProvider) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// ActiveKeyID retorna o kid da KEK gerada pelo último [MemoryKeyProvider.Rotate].
+func (p *MemoryKeyProvider) ActiveKeyID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active
+}
+
+// WrapKey conta a chamada e cifra com a KEK ativa, ou retorna o erro de
+// [MemoryKeyProvider.Fail].
+func (p *MemoryKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wraps++
+	if p.err != nil {
+		return "", nil, p.err
+	}
+	nonce, wrapped, err := sealGCM(p.keys[p.active], dataKey, []byte(p.active))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.active, append(nonce, wrapped...), nil
+}
+
+// UnwrapKey conta a chamada e decifra com a KEK kid, ou retorna o erro de
+// [MemoryKeyProvider.Fail].
+func (p *MemoryKeyProvider) UnwrapKey(ctx context.Context, kid string, wrapped []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.unwraps++
+	if p.err != nil {
+		return nil, p.err
+	}
+	kek, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return openGCM(kek, wrapped, []byte(kid))
+}
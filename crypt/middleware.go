@@ -9,12 +9,23 @@ import (
 	"strings"
 )
 
+// Tipos de descriptografia aceitos por [NewDecryptionMiddleware]. Um tipo
+// vazio não é aceito: os valores retornam erro, como nas versões anteriores.
+const (
+	// DecryptionHybrid descriptografa com RSA + AES ([CryptService.DecryptData]).
+	DecryptionHybrid = "hybrid"
+	// DecryptionAES descriptografa com a chave mestra ([CryptService.DecryptWithMasterKeySimple]).
+	DecryptionAES = "aes"
+	// DecryptionAuto escolhe o algoritmo pelo envelope do valor ([CryptService.Decrypt]).
+	DecryptionAuto = "auto"
+)
+
 // DecryptionMiddleware é um middleware HTTP que descriptografa automaticamente dados criptografados
 type DecryptionMiddleware struct {
 	cryptService *CryptService
 	// Campos que devem ser descriptografados automaticamente
 	encryptedFields []string
-	// Tipo de descriptografia: "hybrid" ou "aes"
+	// Tipo de descriptografia: "hybrid", "aes" ou "auto" (pelo envelope)
 	decryptionType string
 }
 
@@ -27,7 +38,9 @@ func NewDecryptionMiddleware(cryptService *CryptService, encryptedFields []strin
 	}
 }
 
-// Middleware retorna o handler do middleware
+// Middleware retorna o handler do middleware. Os campos descriptografados são
+// gravados no body como texto; até a v1.0.0 eram gravados como []byte, que o
+// encoding/json serializa em base64.
 func (dm *DecryptionMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Só processa requisições com body (POST, PUT, PATCH)
@@ -91,7 +104,7 @@ func (dm *DecryptionMiddleware) decryptFields(data map[string]interface{}) error
 				if err != nil {
 					return fmt.Errorf("erro ao descriptografar campo '%s': %v", field, err)
 				}
-				data[field] = decryptedValue
+				data[field] = string(decryptedValue)
 			}
 		}
 	}
@@ -101,10 +114,12 @@ func (dm *DecryptionMiddleware) decryptFields(data map[string]interface{}) error
 // decryptValue descriptografa um valor usando o tipo de descriptografia configurado
 func (dm *DecryptionMiddleware) decryptValue(encryptedValue string) ([]byte, error) {
 	switch dm.decryptionType {
-	case "hybrid":
+	case DecryptionHybrid:
 		return dm.cryptService.DecryptData(encryptedValue)
-	case "aes":
+	case DecryptionAES:
 		return dm.cryptService.DecryptWithMasterKeySimple(encryptedValue)
+	case DecryptionAuto:
+		return dm.cryptService.Decrypt(encryptedValue)
 	default:
 		return []byte{}, fmt.Errorf("tipo de descriptografia não suportado: %s", dm.decryptionType)
 	}
@@ -114,7 +129,7 @@ func (dm *DecryptionMiddleware) decryptValue(encryptedValue string) ([]byte, err
 type DecryptionConfig struct {
 	// Campos que devem ser descriptografados
 	EncryptedFields []string
-	// Tipo de descriptografia: "hybrid" ou "aes"
+	// Tipo de descriptografia: "hybrid", "aes" ou "auto" (pelo envelope)
 	DecryptionType string
 	// Caminhos das chaves de criptografia
 	RSAPrivateKeyPath  string
@@ -139,25 +154,29 @@ func NewDecryptionMiddlewareFromConfig(config DecryptionConfig) (*DecryptionMidd
 	return NewDecryptionMiddleware(&cryptService, config.EncryptedFields, config.DecryptionType), nil
 }
 
-// MiddlewareFunc retorna uma função middleware compatível com frameworks como Gin, Echo, etc.
+// MiddlewareFunc retorna o middleware no formato func(http.Handler) http.Handler,
+// usado por chi e net/http. Para Gin e Echo, use os adaptadores dos pacotes
+// gincrypt e echocrypt.
 func (dm *DecryptionMiddleware) MiddlewareFunc() func(http.Handler) http.Handler {
 	return dm.Middleware
 }
 
-// GinMiddleware retorna um middleware compatível com Gin
+// GinMiddleware nunca foi implementado: o middleware retornado entra em pânico.
+//
+// Deprecated: use gincrypt.Middleware, do módulo
+// github.com/cgisoftware/initializers/crypt/gincrypt.
 func (dm *DecryptionMiddleware) GinMiddleware() func(c interface{}) {
 	return func(c interface{}) {
-		// Esta função seria implementada especificamente para Gin
-		// Por enquanto, deixamos como placeholder
-		panic("GinMiddleware não implementado - use MiddlewareFunc() com adaptador")
+		panic("GinMiddleware não implementado - use gincrypt.Middleware")
 	}
 }
 
-// EchoMiddleware retorna um middleware compatível com Echo
+// EchoMiddleware nunca foi implementado: o middleware retornado entra em pânico.
+//
+// Deprecated: use echocrypt.Middleware, do módulo
+// github.com/cgisoftware/initializers/crypt/echocrypt.
 func (dm *DecryptionMiddleware) EchoMiddleware() func(next interface{}) interface{} {
 	return func(next interface{}) interface{} {
-		// Esta função seria implementada especificamente para Echo
-		// Por enquanto, deixamos como placeholder
-		panic("EchoMiddleware não implementado - use MiddlewareFunc() com adaptador")
+		panic("EchoMiddleware não implementado - use echocrypt.Middleware")
 	}
 }
@@ -0,0 +1,251 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrPasswordMismatch indica que a senha não confere com o hash.
+	ErrPasswordMismatch = errors.New("senha não confere")
+	// ErrInvalidPasswordHash indica um hash em formato desconhecido ou com parâmetros inválidos.
+	ErrInvalidPasswordHash = errors.New("hash de senha inválido")
+)
+
+// PasswordAlgorithm identifica o algoritmo de hash de senhas.
+type PasswordAlgorithm string
+
+const (
+	// PasswordArgon2id é o Argon2id (RFC 9106), codificado no formato PHC:
+	// $argon2id$v=19$m=<KiB>,t=<iterações>,p=<paralelismo>$<salt>$<hash>
+	PasswordArgon2id PasswordAlgorithm = "argon2id"
+	// PasswordBcrypt é o bcrypt, no formato $2a$<custo>$<salt+hash>.
+	PasswordBcrypt PasswordAlgorithm = "bcrypt"
+)
+
+// Padrões do Argon2id, que seguem as recomendações da OWASP
+const (
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+)
+
+// argon2LimitFactor limita os parâmetros lidos de um hash Argon2id a este
+// múltiplo dos configurados (ou dos padrões, se maiores)
+const argon2LimitFactor = 4
+
+// PasswordConfig configura o [PasswordHasher]. Campos zerados usam os padrões,
+// que seguem as recomendações da OWASP.
+type PasswordConfig struct {
+	// Algorithm é o algoritmo dos novos hashes. Padrão [PasswordArgon2id].
+	Algorithm PasswordAlgorithm
+	// Memory é a memória do Argon2id em KiB. Padrão 65536 (64 MiB).
+	Memory uint32
+	// Iterations é o número de passadas do Argon2id. Padrão 3.
+	Iterations uint32
+	// Parallelism é o número de threads do Argon2id. Padrão 2.
+	Parallelism uint8
+	// SaltLength é o tamanho do salt do Argon2id em bytes. Padrão 16.
+	SaltLength uint32
+	// KeyLength é o tamanho do hash do Argon2id em bytes. Padrão 32.
+	KeyLength uint32
+	// Cost é o custo do bcrypt. Padrão 12.
+	Cost int
+}
+
+// PasswordHasher gera e verifica hashes de senhas com Argon2id ou bcrypt.
+//
+// Hashes de qualquer um dos algoritmos são verificados, independentemente da
+// configuração; [PasswordHasher.NeedsRehash] indica os que devem ser
+// regerados após uma mudança de algoritmo ou de parâmetros.
+type PasswordHasher struct {
+	config PasswordConfig
+}
+
+// NewPasswordHasher cria um [PasswordHasher] com a configuração informada.
+//
+//	hasher := crypt.NewPasswordHasher(crypt.PasswordConfig{})                                 // Argon2id, padrões
+//	hasher := crypt.NewPasswordHasher(crypt.PasswordConfig{Algorithm: crypt.PasswordBcrypt}) // bcrypt, custo 12
+func NewPasswordHasher(config PasswordConfig) *PasswordHasher {
+	if config.Algorithm == "" {
+		config.Algorithm = PasswordArgon2id
+	}
+	if config.Memory == 0 {
+		config.Memory = defaultArgon2Memory
+	}
+	if config.Iterations == 0 {
+		config.Iterations = defaultArgon2Iterations
+	}
+	if config.Parallelism == 0 {
+		config.Parallelism = defaultArgon2Parallelism
+	}
+	if config.SaltLength == 0 {
+		config.SaltLength = 16
+	}
+	if config.KeyLength == 0 {
+		config.KeyLength = 32
+	}
+	if config.Cost == 0 {
+		config.Cost = 12
+	}
+	return &PasswordHasher{config: config}
+}
+
+// defaultPasswordHasher é usado por [HashPassword] e [VerifyPassword]
+var defaultPasswordHasher = NewPasswordHasher(PasswordConfig{})
+
+// HashPassword gera o hash da senha com Argon2id e os parâmetros padrão.
+func HashPassword(password string) (string, error) {
+	return defaultPasswordHasher.Hash(password)
+}
+
+// VerifyPassword verifica a senha contra um hash Argon2id ou bcrypt. Retorna
+// [ErrPasswordMismatch] se a senha não conferir.
+func VerifyPassword(password, encodedHash string) error {
+	return defaultPasswordHasher.Verify(password, encodedHash)
+}
+
+// IsPasswordHash informa se o valor é um hash produzido pelo [PasswordHasher],
+// distinguindo-o de uma senha criptografada por [CryptManager.EncryptPassword].
+func IsPasswordHash(value string) bool {
+	return strings.HasPrefix(value, "$argon2id$") || strings.HasPrefix(value, "$2a$") ||
+		strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
+
+// Hash gera o hash da senha com o algoritmo e os parâmetros configurados e um
+// salt aleatório.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch h.config.Algorithm {
+	case PasswordArgon2id:
+		return h.hashArgon2id(password)
+	case PasswordBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.Cost)
+		if err != nil {
+			return "", fmt.Errorf("erro ao gerar hash bcrypt: %v", err)
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("algoritmo de hash de senha não suportado: %s", h.config.Algorithm)
+	}
+}
+
+// Verify verifica a senha contra um hash Argon2id ou bcrypt, em tempo
+// constante. Retorna [ErrPasswordMismatch] se a senha não conferir e
+// [ErrInvalidPasswordHash] se o hash não puder ser lido.
+//
+// Hashes Argon2id com memória, iterações ou paralelismo acima de 4 vezes os
+// configurados (ou os padrões, se maiores) são rejeitados sem calcular o hash,
+// para que um hash adulterado no banco não provoque alocações enormes.
+func (h *PasswordHasher) Verify(password, encodedHash string) error {
+	if strings.HasPrefix(encodedHash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2id(encodedHash, h.argon2Limit())
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return ErrPasswordMismatch
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidPasswordHash, err)
+	}
+}
+
+// NeedsRehash informa se o hash deve ser regerado por usar outro algoritmo ou
+// parâmetros diferentes dos configurados. Gere o novo hash com
+// [PasswordHasher.Hash] após uma verificação bem-sucedida, quando a senha em
+// texto puro está disponível.
+func (h *PasswordHasher) NeedsRehash(encodedHash string) bool {
+	switch h.config.Algorithm {
+	case PasswordArgon2id:
+		params, salt, key, err := decodeArgon2id(encodedHash, h.argon2Limit())
+		return err != nil ||
+			params.Memory != h.config.Memory ||
+			params.Iterations != h.config.Iterations ||
+			params.Parallelism != h.config.Parallelism ||
+			uint32(len(salt)) != h.config.SaltLength ||
+			uint32(len(key)) != h.config.KeyLength
+	case PasswordBcrypt:
+		cost, err := bcrypt.Cost([]byte(encodedHash))
+		return err != nil || cost != h.config.Cost
+	default:
+		return true
+	}
+}
+
+// hashArgon2id gera o hash Argon2id no formato PHC
+func (h *PasswordHasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, h.config.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("erro ao gerar salt: %v", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.config.Iterations, h.config.Memory, h.config.Parallelism, h.config.KeyLength)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.config.Memory, h.config.Iterations, h.config.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// argon2Limit retorna os maiores parâmetros aceitos em um hash Argon2id
+func (h *PasswordHasher) argon2Limit() PasswordConfig {
+	return PasswordConfig{
+		Memory:      argon2LimitFactor * max(h.config.Memory, defaultArgon2Memory),
+		Iterations:  argon2LimitFactor * max(h.config.Iterations, defaultArgon2Iterations),
+		Parallelism: uint8(min(argon2LimitFactor*max(uint32(h.config.Parallelism), defaultArgon2Parallelism), math.MaxUint8)),
+	}
+}
+
+// decodeArgon2id lê os parâmetros, o salt e o hash de um hash Argon2id no
+// formato PHC, rejeitando parâmetros acima de limit
+func decodeArgon2id(encodedHash string, limit PasswordConfig) (PasswordConfig, []byte, []byte, error) {
+	var params PasswordConfig
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != string(PasswordArgon2id) {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: versão %q", ErrInvalidPasswordHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: parâmetros %q", ErrInvalidPasswordHash, parts[3])
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 || params.Memory < 8*uint32(params.Parallelism) {
+		return params, nil, nil, fmt.Errorf("%w: parâmetros %q", ErrInvalidPasswordHash, parts[3])
+	}
+	if params.Memory > limit.Memory || params.Iterations > limit.Iterations || params.Parallelism > limit.Parallelism {
+		return params, nil, nil, fmt.Errorf("%w: parâmetros %q acima do limite", ErrInvalidPasswordHash, parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: salt: %v", ErrInvalidPasswordHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: hash", ErrInvalidPasswordHash)
+	}
+	return params, salt, key, nil
+}
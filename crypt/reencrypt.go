@@ -0,0 +1,168 @@
+package crypt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Database é o subconjunto de types.Database (pacote postgres) usado por
+// [CryptService.ReencryptTable]. Declarado aqui para evitar a dependência do
+// módulo postgres; qualquer types.Database o satisfaz.
+type Database interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ReencryptConfig configura a re-criptografia de uma coluna com
+// [CryptService.ReencryptTable].
+type ReencryptConfig struct {
+	// Table é a tabela com os dados criptografados.
+	Table string
+	// Column é a coluna texto com os valores produzidos pelo [CryptService].
+	Column string
+	// KeyColumn é a chave primária, usada para percorrer a tabela em ordem e
+	// comparada no tipo da própria coluna (inteiro, uuid, texto). Padrão "id".
+	KeyColumn string
+	// BatchSize é o número de linhas lidas por vez. Padrão 500.
+	BatchSize int
+	// OnBatch é chamado ao fim de cada lote com o progresso acumulado.
+	OnBatch func(result ReencryptResult)
+	// OnError é chamado para cada linha que não pôde ser descriptografada. A
+	// linha é mantida e o job continua.
+	OnError func(key string, err error)
+}
+
+// ReencryptResult é o progresso de [CryptService.ReencryptTable].
+type ReencryptResult struct {
+	// Scanned é o número de linhas lidas.
+	Scanned int
+	// Reencrypted é o número de linhas gravadas com a chave ativa.
+	Reencrypted int
+	// Skipped é o número de linhas alteradas por outra transação durante o
+	// job; rode-o novamente para migrá-las.
+	Skipped int
+	// Failed é o número de linhas que não puderam ser descriptografadas.
+	Failed int
+	// LastKey é a chave da última linha processada, formatada como texto.
+	LastKey string
+}
+
+// ReencryptTable migra os valores de uma coluna para as chaves atuais do
+// serviço (ver [CryptService.Reencrypt]), em lotes, sem bloquear a tabela.
+//
+// Cada linha é atualizada apenas se o valor não mudou desde a leitura, então
+// o job pode rodar com a aplicação no ar. Após um job sem falhas e sem linhas
+// puladas, a chave aposentada pode ser removida com [Keyring.Remove].
+//
+//	service.Keyring().Rotate(novaChave)
+//	result, err := service.ReencryptTable(ctx, db, crypt.ReencryptConfig{
+//	    Table:  "clientes",
+//	    Column: "cpf",
+//	})
+func (cs *CryptService) ReencryptTable(ctx context.Context, db Database, config ReencryptConfig) (ReencryptResult, error) {
+	if config.KeyColumn == "" {
+		config.KeyColumn = "id"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+
+	first := fmt.Sprintf(
+		`SELECT %[1]s, %[2]s FROM %[3]s WHERE %[2]s IS NOT NULL AND %[2]s <> '' ORDER BY %[1]s LIMIT $1`,
+		config.KeyColumn, config.Column, config.Table,
+	)
+	next := fmt.Sprintf(
+		`SELECT %[1]s, %[2]s FROM %[3]s WHERE %[2]s IS NOT NULL AND %[2]s <> '' AND %[1]s > $2 ORDER BY %[1]s LIMIT $1`,
+		config.KeyColumn, config.Column, config.Table,
+	)
+	update := fmt.Sprintf(
+		`UPDATE %s SET %s = $1 WHERE %s = $2 AND %s = $3`,
+		config.Table, config.Column, config.KeyColumn, config.Column,
+	)
+
+	var result ReencryptResult
+	var lastID any
+	for {
+		query, args := first, []any{config.BatchSize}
+		if result.Scanned > 0 {
+			query, args = next, []any{config.BatchSize, lastID}
+		}
+
+		rows, err := cs.readBatch(ctx, db, query, args...)
+		if err != nil {
+			return result, err
+		}
+
+		for _, row := range rows {
+			result.Scanned++
+			result.LastKey = row.key
+			lastID = row.id
+
+			reencrypted, changed, err := cs.reencrypt(ctx, row.value)
+			if err != nil {
+				result.Failed++
+				if config.OnError != nil {
+					config.OnError(row.key, err)
+				}
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			res, err := db.ExecContext(ctx, update, reencrypted, row.id, row.value)
+			if err != nil {
+				return result, fmt.Errorf("erro ao gravar linha %s: %v", row.key, err)
+			}
+			if n, err := res.RowsAffected(); err == nil && n == 0 {
+				result.Skipped++
+				continue
+			}
+			result.Reencrypted++
+		}
+
+		if config.OnBatch != nil && len(rows) > 0 {
+			config.OnBatch(result)
+		}
+		if len(rows) < config.BatchSize {
+			return result, nil
+		}
+	}
+}
+
+// reencryptRow é uma linha lida por ReencryptTable. id é a chave no tipo da
+// coluna, repassada às consultas; key é a mesma chave formatada como texto.
+type reencryptRow struct {
+	id    any
+	key   string
+	value string
+}
+
+// readBatch lê um lote de linhas
+func (cs *CryptService) readBatch(ctx context.Context, db Database, query string, args ...any) ([]reencryptRow, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler lote: %v", err)
+	}
+	defer rows.Close()
+
+	var batch []reencryptRow
+	for rows.Next() {
+		var row reencryptRow
+		if err := rows.Scan(&row.id, &row.value); err != nil {
+			return nil, fmt.Errorf("erro ao ler linha: %v", err)
+		}
+		// alguns drivers retornam uuid e numeric como []byte, que seria
+		// enviado de volta como bytea
+		if b, ok := row.id.([]byte); ok {
+			row.id = string(b)
+		}
+		row.key = fmt.Sprint(row.id)
+		batch = append(batch, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler lote: %v", err)
+	}
+	return batch, nil
+}
@@ -0,0 +1,172 @@
+package crypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tokenPrefix identifica um token v2 e o distingue do formato CBC
+// (base64(ciphertext)-base64(iv)), que nunca contém ponto
+const tokenPrefix = "v2."
+
+var (
+	// ErrInvalidToken indica um token malformado, adulterado ou gerado com
+	// outra chave ou outros dados associados. O motivo não é detalhado para não
+	// servir de oráculo.
+	ErrInvalidToken = errors.New("token inválido")
+	// ErrTokenExpired indica um token autêntico cuja validade terminou.
+	ErrTokenExpired = errors.New("token expirado")
+	// ErrLegacyToken indica um token no formato CBC anterior, lido apenas com
+	// [TokenOptions].AllowLegacy.
+	ErrLegacyToken = errors.New("token no formato CBC anterior não permitido")
+)
+
+// TokenOptions configura [GenerateTokenV2] e [DecryptTokenV2].
+type TokenOptions struct {
+	// TTL é a validade do token gerado. Zero gera um token sem expiração.
+	TTL time.Duration
+	// AssociatedData é autenticado junto com o token, mas não faz parte dele
+	// (ex.: o id do usuário ou o propósito do token). O mesmo valor deve ser
+	// informado na geração e na leitura.
+	AssociatedData []byte
+	// AllowLegacy faz [DecryptTokenV2] aceitar tokens CBC de versões
+	// anteriores de [GenerateToken]. Esses tokens não têm verificação de
+	// integridade; habilite apenas durante a migração.
+	AllowLegacy bool
+	// Now é o relógio usado na expiração. Padrão time.Now.
+	Now func() time.Time
+}
+
+// now retorna o horário atual pelo relógio configurado
+func (o TokenOptions) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// GenerateTokenV2 cifra os dados em um token AES-GCM autenticado:
+//
+//	"v2." + base64url(expiração (8 bytes, unix) | nonce | ciphertext)
+//
+// A expiração (0 se não houver) e opts.AssociatedData são autenticados como
+// dado adicional do GCM. A chave deve ter 16, 24 ou 32 bytes.
+//
+//	token, err := crypt.GenerateTokenV2(ctx, key, []byte(userID), crypt.TokenOptions{
+//	    TTL:            15 * time.Minute,
+//	    AssociatedData: []byte("reset-senha"),
+//	})
+func GenerateTokenV2(ctx context.Context, key []byte, data []byte, opts TokenOptions) (string, error) {
+	var expiresAt int64
+	if opts.TTL > 0 {
+		expiresAt = opts.now().Add(opts.TTL).Unix()
+	}
+
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return sealToken(aesGCM, nonce, expiresAt, data, opts.AssociatedData), nil
+}
+
+// DecryptTokenV2 verifica e decifra um token de [GenerateTokenV2]. Retorna
+// [ErrInvalidToken] para qualquer falha de formato ou autenticação,
+// [ErrTokenExpired] se a validade terminou e [ErrLegacyToken] para tokens CBC
+// sem opts.AllowLegacy.
+func DecryptTokenV2(ctx context.Context, key []byte, token string, opts TokenOptions) ([]byte, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		if !opts.AllowLegacy {
+			return nil, ErrLegacyToken
+		}
+		return decryptLegacyToken(key, token)
+	}
+
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := base64.RawURLEncoding.DecodeString(token[len(tokenPrefix):])
+	if err != nil || len(body) < 8+aesGCM.NonceSize()+aesGCM.Overhead() {
+		return nil, ErrInvalidToken
+	}
+
+	header, nonce, ciphertext := body[:8], body[8:8+aesGCM.NonceSize()], body[8+aesGCM.NonceSize():]
+	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, tokenAdditionalData(header, opts.AssociatedData))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// a expiração só é avaliada depois da autenticação
+	if expiresAt := int64(binary.BigEndian.Uint64(header)); expiresAt != 0 && !opts.now().Before(time.Unix(expiresAt, 0)) {
+		return nil, ErrTokenExpired
+	}
+	return plaintext, nil
+}
+
+// sealToken monta um token v2 com o nonce informado
+func sealToken(aesGCM cipher.AEAD, nonce []byte, expiresAt int64, data, associatedData []byte) string {
+	header := binary.BigEndian.AppendUint64(nil, uint64(expiresAt))
+	body := append(header, nonce...)
+	body = aesGCM.Seal(body, nonce, data, tokenAdditionalData(header, associatedData))
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(body)
+}
+
+// tokenAdditionalData monta o dado adicional autenticado de um token v2
+func tokenAdditionalData(header, associatedData []byte) []byte {
+	additionalData := append([]byte(tokenPrefix), header...)
+	return append(additionalData, associatedData...)
+}
+
+// decryptLegacyToken decifra um token CBC (base64(ciphertext)-base64(iv)) das
+// versões anteriores de [GenerateToken]. Todas as falhas retornam
+// [ErrInvalidToken], sem distinguir erros de padding.
+func decryptLegacyToken(key []byte, token string) ([]byte, error) {
+	cipherText, iv, found := strings.Cut(token, "-")
+	if !found {
+		return nil, ErrInvalidToken
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	nonce, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(nonce) != aes.BlockSize {
+		return nil, ErrInvalidToken
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrInvalidToken
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, nonce).CryptBlocks(plaintext, ciphertext)
+
+	// verifica o padding PKCS7 sem desvios dependentes do seu conteúdo
+	padding := int(plaintext[len(plaintext)-1])
+	good := subtle.ConstantTimeLessOrEq(1, padding) & subtle.ConstantTimeLessOrEq(padding, aes.BlockSize)
+	for i := 1; i <= aes.BlockSize; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, padding)
+		matches := subtle.ConstantTimeByteEq(plaintext[len(plaintext)-i], byte(padding))
+		good &= subtle.ConstantTimeSelect(inPadding, matches, 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidToken
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}